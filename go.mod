@@ -3,6 +3,9 @@ module ovh-dns-manager
 go 1.21
 
 require (
+	filippo.io/age v1.1.1
+	github.com/BurntSushi/toml v1.4.0
+	github.com/miekg/dns v1.1.58
 	github.com/spf13/cobra v1.9.1
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -10,4 +13,9 @@ require (
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
+	golang.org/x/crypto v0.18.0 // indirect
+	golang.org/x/mod v0.14.0 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/tools v0.17.0 // indirect
 )
@@ -0,0 +1,186 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/ovh"
+	"ovh-dns-manager/internal/resolve"
+)
+
+const (
+	// ChallengeLabel is the label under which DNS-01 challenges are published
+	ChallengeLabel = config.ACMEChallengeLabel
+	// DefaultTTL keeps challenge records short-lived in resolver caches
+	DefaultTTL = 60
+)
+
+// Hook publishes and removes DNS-01 challenge TXT records
+type Hook struct {
+	client *ovh.Client
+	zone   string
+	ttl    int
+}
+
+// NewHook creates a hook. When zone is empty, the zone of each challenge is
+// found among the zones of the account.
+func NewHook(client *ovh.Client, zone string, ttl int) *Hook {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Hook{
+		client: client,
+		zone:   zone,
+		ttl:    ttl,
+	}
+}
+
+// ChallengeFQDN returns the challenge name for a domain, accepting either the
+// domain being validated or the full challenge name
+func ChallengeFQDN(domain string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(domain, "*."), ".")
+	if strings.HasPrefix(name, ChallengeLabel+".") {
+		return name
+	}
+	return ChallengeLabel + "." + name
+}
+
+// Present creates the challenge TXT record and refreshes the zone. It
+// returns the challenge name.
+func (h *Hook) Present(domain, value string) (string, error) {
+	fqdn := ChallengeFQDN(domain)
+	zone, subDomain, err := h.split(fqdn)
+	if err != nil {
+		return fqdn, err
+	}
+
+	existing, err := h.findChallenges(zone, subDomain, value)
+	if err != nil {
+		return fqdn, err
+	}
+	if len(existing) > 0 {
+		slog.Info("Challenge record already present", "op", "keep", "zone", zone, "key", subDomain+":TXT", "id", existing[0].ID, "fqdn", fqdn)
+		return fqdn, nil
+	}
+
+	slog.Info("Creating challenge record", "op", "create", "zone", zone, "key", subDomain+":TXT", "fqdn", fqdn, "target", value)
+	_, err = h.client.CreateRecord(zone, &config.OVHRecordCreate{
+		SubDomain: subDomain,
+		FieldType: "TXT",
+		Target:    value,
+		TTL:       h.ttl,
+	})
+	if err != nil {
+		return fqdn, fmt.Errorf("failed to create challenge record %s: %w", fqdn, err)
+	}
+
+	slog.Info("Refreshing DNS zone", "op", "refresh", "zone", zone)
+	return fqdn, h.client.RefreshZone(zone)
+}
+
+// Cleanup deletes the challenge TXT records holding value and refreshes the
+// zone. Challenges for other pending orders are left in place.
+func (h *Hook) Cleanup(domain, value string) error {
+	fqdn := ChallengeFQDN(domain)
+	zone, subDomain, err := h.split(fqdn)
+	if err != nil {
+		return err
+	}
+
+	existing, err := h.findChallenges(zone, subDomain, value)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		slog.Info("No challenge record to clean up", "op", "delete", "zone", zone, "key", subDomain+":TXT", "fqdn", fqdn)
+		return nil
+	}
+
+	for _, record := range existing {
+		slog.Info("Deleting challenge record", "op", "delete", "zone", zone, "key", subDomain+":TXT", "id", record.ID,
+			"fqdn", fqdn, "target", record.Target)
+		if err := h.client.DeleteRecord(zone, record.ID); err != nil {
+			return fmt.Errorf("failed to delete challenge record %s: %w", fqdn, err)
+		}
+	}
+
+	slog.Info("Refreshing DNS zone", "op", "refresh", "zone", zone)
+	return h.client.RefreshZone(zone)
+}
+
+// Wait blocks until every authoritative server of the zone answers the
+// challenge name with value, or the timeout expires
+func (h *Hook) Wait(domain, value string, timeout time.Duration) error {
+	fqdn := ChallengeFQDN(domain)
+	zone, _, err := h.split(fqdn)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := resolve.NewAuthoritativeResolver(h.client, zone, resolve.DefaultTimeout)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	slog.Info("Waiting for challenge record", "op", "wait", "zone", zone, "fqdn", fqdn, "servers", resolver.Servers())
+	return resolver.WaitUntil(ctx, fqdn, dns.TypeTXT, 5*time.Second, func(records []dns.RR) bool {
+		for _, txt := range resolve.TXTValues(records) {
+			if txt == unquote(value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (h *Hook) findChallenges(zone, subDomain, value string) ([]config.OVHRecord, error) {
+	records, err := h.client.FindRecords(zone, subDomain, "TXT")
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []config.OVHRecord
+	for _, record := range records {
+		if unquote(record.Target) == unquote(value) {
+			matching = append(matching, record)
+		}
+	}
+	return matching, nil
+}
+
+// split returns the zone holding fqdn and the subdomain of fqdn in that zone
+func (h *Hook) split(fqdn string) (string, string, error) {
+	zones := []string{h.zone}
+	if h.zone == "" {
+		var err error
+		zones, err = h.client.ListZones()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	best := ""
+	for _, zone := range zones {
+		if strings.HasSuffix(fqdn, "."+zone) && len(zone) > len(best) {
+			best = zone
+		}
+	}
+	if best == "" {
+		return "", "", fmt.Errorf("no OVH zone found for %s", fqdn)
+	}
+
+	return best, strings.TrimSuffix(fqdn, "."+best), nil
+}
+
+// unquote strips the quotes OVH adds around TXT targets
+func unquote(value string) string {
+	return strings.Trim(value, `"`)
+}
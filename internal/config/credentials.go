@@ -0,0 +1,509 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"ovh-dns-manager/internal/redact"
+)
+
+// StrictPermissions makes credentials files readable by other users an error
+// instead of a warning
+var StrictPermissions bool
+
+// yamlSnippet matches the excerpts of values yaml.v3 quotes in its errors
+var yamlSnippet = regexp.MustCompile("`[^`]*`")
+
+// DefaultCredentialsFile is the YAML credentials file read when none is given.
+// Unlike an explicitly chosen file, it may be missing.
+const DefaultCredentialsFile = "ovh-credentials.yaml"
+
+// credentialsFileContent is the layout of the YAML credentials file: the
+// default account at the top level and named accounts under profiles
+type credentialsFileContent struct {
+	OVHCredentials `yaml:",inline"`
+	Profiles       map[string]OVHCredentials `yaml:"profiles"`
+}
+
+// UsesOAuth2 reports whether the credentials are for an OAuth2 service
+// account rather than application and consumer keys
+func (c *OVHCredentials) UsesOAuth2() bool {
+	return c.ClientID != "" || c.ClientSecret != ""
+}
+
+// complete reports whether the keys needed to authenticate are all set
+func (c *OVHCredentials) complete() bool {
+	if c.UsesOAuth2() {
+		return c.ClientID != "" && c.ClientSecret != ""
+	}
+	return c.ApplicationKey != "" && c.ApplicationSecret != "" && c.ConsumerKey != ""
+}
+
+// fillFrom sets the values of c that are still unset from other
+func (c *OVHCredentials) fillFrom(other OVHCredentials) {
+	if c.Endpoint == "" {
+		c.Endpoint = other.Endpoint
+	}
+	if c.ApplicationKey == "" {
+		c.ApplicationKey = other.ApplicationKey
+	}
+	if c.ApplicationSecret == "" {
+		c.ApplicationSecret = other.ApplicationSecret
+	}
+	if c.ConsumerKey == "" {
+		c.ConsumerKey = other.ConsumerKey
+	}
+	if c.ClientID == "" {
+		c.ClientID = other.ClientID
+	}
+	if c.ClientSecret == "" {
+		c.ClientSecret = other.ClientSecret
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = other.TokenURL
+	}
+	if c.Timeout == 0 {
+		c.Timeout = other.Timeout
+	}
+}
+
+// ResolveOVHApplication returns the endpoint, application key and secret
+// to request a new consumer key for profile with, from the same sources as
+// LoadOVHCredentials. A missing credentials file or profile is not an error:
+// the values of the default account are used instead, and values may be
+// missing altogether.
+func ResolveOVHApplication(filename, profile string) (*OVHCredentials, error) {
+	creds := OVHCredentials{
+		Endpoint:          getEnvOrDefault("OVH_ENDPOINT", ""),
+		ApplicationKey:    getEnvOrDefault("OVH_APPLICATION_KEY", ""),
+		ApplicationSecret: getEnvOrDefault("OVH_APPLICATION_SECRET", ""),
+		Timeout:           getEnvIntOrDefault("OVH_TIMEOUT", 0),
+	}
+
+	if profile == "" {
+		profile = getEnvOrDefault("OVH_PROFILE", "")
+	}
+	profiles := []string{profile}
+	if profile != "" {
+		profiles = append(profiles, "")
+	}
+
+	for _, name := range profiles {
+		fileCreds, _, err := loadCredentialsFile(filename, name, true)
+		if err != nil {
+			return nil, err
+		}
+		creds.fillFrom(fileCreds)
+	}
+	for _, name := range profiles {
+		confCreds, _, err := loadOVHConf(name, creds.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		creds.fillFrom(confCreds)
+	}
+
+	if creds.Endpoint == "" {
+		creds.Endpoint = "ovh-eu"
+	}
+	creds.ConsumerKey = ""
+
+	if err := creds.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	return &creds, nil
+}
+
+// SaveOVHCredentials writes creds to the YAML credentials file, at the top
+// level or under profiles when profile is set, keeping the other content of
+// an existing file. The file is only readable by its owner.
+func SaveOVHCredentials(filename, profile string, creds *OVHCredentials) error {
+	filename = credentialsFileName(filename)
+
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	data, encrypted, err := readFile(filename)
+	switch {
+	case encrypted:
+		return fmt.Errorf("credentials file %s is encrypted and cannot be rewritten, edit it with sops or age instead", filename)
+	case err == nil:
+		if err := yaml.Unmarshal(data, doc); err != nil {
+			return fmt.Errorf("failed to parse credentials YAML: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to read credentials file %s: %w", filename, err)
+	}
+
+	target := doc.Content[0]
+	if target.Kind != yaml.MappingNode {
+		return fmt.Errorf("credentials file %s must contain a mapping", filename)
+	}
+	if profile != "" {
+		target = childMapping(childMapping(target, "profiles"), profile)
+	}
+
+	fields := []struct {
+		key   string
+		value string
+	}{
+		{"endpoint", creds.Endpoint},
+		{"application_key", creds.ApplicationKey},
+		{"application_secret", creds.ApplicationSecret},
+		{"consumer_key", creds.ConsumerKey},
+	}
+	for _, field := range fields {
+		// Keep secret references pointing to the value being written
+		if existing := mappingValue(target, field.key); existing != nil && existing.Value != field.value {
+			if resolved, err := resolveSecret(existing.Value); err == nil && resolved == field.value {
+				continue
+			}
+		}
+		if err := setMappingValue(target, field.key, field.value); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	return writePrivateFile(filename, buf.Bytes())
+}
+
+// writePrivateFile replaces filename with data through a temporary file
+// readable by the owner only, so secrets never land in a file with wider
+// permissions, even when filename already exists with them
+func writePrivateFile(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write credentials file %s: %w", filename, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp asks for 0600 before the umask applies, set it exactly
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to restrict permissions of %s: %w", filename, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials file %s: %w", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write credentials file %s: %w", filename, err)
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to write credentials file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// childMapping returns the mapping stored under key, replacing any other
+// value and creating it when missing
+func childMapping(node *yaml.Node, key string) *yaml.Node {
+	if value := mappingValue(node, key); value != nil {
+		if value.Kind != yaml.MappingNode {
+			*value = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		return value
+	}
+
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+	return value
+}
+
+// resolveSecrets replaces the secret references of credential values with
+// the values they point to
+func (c *OVHCredentials) resolveSecrets() error {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"application_key", &c.ApplicationKey},
+		{"application_secret", &c.ApplicationSecret},
+		{"consumer_key", &c.ConsumerKey},
+		{"client_id", &c.ClientID},
+		{"client_secret", &c.ClientSecret},
+	}
+
+	for _, field := range fields {
+		resolved, err := resolveSecret(*field.value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", field.name, err)
+		}
+		*field.value = resolved
+	}
+
+	return nil
+}
+
+// resolveSecret returns the value a secret reference points to:
+//   - file:PATH, the content of a file such as a Docker or Kubernetes secret
+//   - exec:COMMAND ARGS..., the output of a command such as a password
+//     manager, split on spaces and run without a shell
+//   - env:NAME, the value of an environment variable
+//
+// Trailing newlines are removed. Other values are returned unchanged.
+func resolveSecret(value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+
+	var resolved string
+	switch scheme {
+	case "file":
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		resolved = string(data)
+	case "exec":
+		args := strings.Fields(ref)
+		if len(args) == 0 {
+			return "", fmt.Errorf("exec: secret reference has no command")
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run %s: %w", args[0], err)
+		}
+		resolved = string(output)
+	case "env":
+		var set bool
+		resolved, set = os.LookupEnv(ref)
+		if !set {
+			return "", fmt.Errorf("environment variable %s is not set", ref)
+		}
+	default:
+		return value, nil
+	}
+
+	resolved = strings.TrimRight(resolved, "\r\n")
+	if resolved == "" {
+		return "", fmt.Errorf("secret %s is empty", value)
+	}
+	return resolved, nil
+}
+
+// loadCredentialsFile reads the credentials of a profile from the YAML
+// credentials file, profile values taking precedence over the top-level ones.
+// It reports whether the file defines the profile. A missing file is only an
+// error when optional is false.
+func loadCredentialsFile(filename, profile string, optional bool) (OVHCredentials, bool, error) {
+	filename = credentialsFileName(filename)
+
+	data, encrypted, err := readFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && optional {
+			return OVHCredentials{}, false, nil
+		}
+		return OVHCredentials{}, false, fmt.Errorf("failed to read credentials file %s: %w", filename, err)
+	}
+	if !encrypted {
+		if err := checkPermissions(filename); err != nil {
+			return OVHCredentials{}, false, err
+		}
+	}
+
+	var content credentialsFileContent
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		// Drop the excerpts of values yaml.v3 quotes, which may be secrets
+		return OVHCredentials{}, false, fmt.Errorf("failed to parse credentials YAML: %s", yamlSnippet.ReplaceAllString(err.Error(), redact.Placeholder))
+	}
+
+	if profile == "" {
+		return content.OVHCredentials, false, nil
+	}
+
+	creds, ok := content.Profiles[profile]
+	if ok {
+		creds.fillFrom(content.OVHCredentials)
+	}
+	return creds, ok, nil
+}
+
+func credentialsFileName(filename string) string {
+	if filename == "" {
+		return DefaultCredentialsFile
+	}
+	return filename
+}
+
+// ovhConfPaths returns the ovh.conf files read by the official OVH clients,
+// in order of precedence
+func ovhConfPaths() []string {
+	paths := []string{"ovh.conf"}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".ovh.conf"))
+	}
+	return append(paths, "/etc/ovh.conf")
+}
+
+// loadOVHConf reads credentials from the ovh.conf files. The section named
+// after the profile, or [default] without one, gives the endpoint; keys are
+// read from that section, then from the section named after the endpoint.
+// endpoint, when set, overrides the endpoint of the section. It reports
+// whether a section named after the profile exists.
+func loadOVHConf(profile, endpoint string) (OVHCredentials, bool, error) {
+	sections := make(map[string]map[string]string)
+	for _, path := range ovhConfPaths() {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return OVHCredentials{}, false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		fileSections, err := parseINI(data)
+		if err != nil {
+			return OVHCredentials{}, false, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if hasSecrets(fileSections) {
+			if err := checkPermissions(path); err != nil {
+				return OVHCredentials{}, false, err
+			}
+		}
+		for name, values := range fileSections {
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			for key, value := range values {
+				if _, ok := sections[name][key]; !ok {
+					sections[name][key] = value
+				}
+			}
+		}
+	}
+
+	section := "default"
+	if profile != "" {
+		section = profile
+	}
+	_, found := sections[section]
+
+	if endpoint == "" {
+		endpoint = sections[section]["endpoint"]
+	}
+	if endpoint == "" {
+		endpoint = "ovh-eu"
+	}
+
+	var creds OVHCredentials
+	for _, values := range []map[string]string{sections[section], sections[endpoint]} {
+		timeout, _ := strconv.Atoi(values["timeout"])
+		creds.fillFrom(OVHCredentials{
+			Endpoint:          values["endpoint"],
+			ApplicationKey:    values["application_key"],
+			ApplicationSecret: values["application_secret"],
+			ConsumerKey:       values["consumer_key"],
+			ClientID:          values["client_id"],
+			ClientSecret:      values["client_secret"],
+			TokenURL:          values["token_url"],
+			Timeout:           timeout,
+		})
+	}
+	if creds.Endpoint == "" {
+		creds.Endpoint = endpoint
+	}
+
+	return creds, profile != "" && found, nil
+}
+
+// hasSecrets reports whether ovh.conf sections hold secrets, as opposed to
+// only selecting an endpoint
+func hasSecrets(sections map[string]map[string]string) bool {
+	for _, values := range sections {
+		for _, key := range []string{"application_secret", "consumer_key", "client_secret"} {
+			if values[key] != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkPermissions warns about a file holding secrets that other users can
+// read, or refuses it with StrictPermissions
+func checkPermissions(filename string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil || info.Mode().Perm()&0044 == 0 {
+		return nil
+	}
+
+	problem := fmt.Sprintf("credentials file %s is readable by other users (mode %04o), run chmod 600 %s", filename, info.Mode().Perm(), filename)
+	if StrictPermissions {
+		return fmt.Errorf("%s", problem)
+	}
+	slog.Warn("Credentials file is readable by other users", "file", filename, "mode", fmt.Sprintf("%04o", info.Mode().Perm()), "fix", "chmod 600 "+filename)
+	return nil
+}
+
+// parseINI parses the INI dialect of ovh.conf: [sections] holding
+// "key = value" or "key: value" lines, with ; and # comments
+func parseINI(data []byte) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			current = sections[name]
+			continue
+		}
+
+		separator := strings.IndexAny(line, "=:")
+		if separator < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: key outside of a section", lineNumber)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(line[:separator]))
+		current[key] = strings.TrimSpace(line[separator+1:])
+	}
+
+	return sections, scanner.Err()
+}
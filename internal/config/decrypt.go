@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile reads a zone or credentials file, decrypting it when it is
+// encrypted with age or SOPS. It reports whether the file was encrypted.
+func readFile(filename string) ([]byte, bool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case isAgeEncrypted(data):
+		plaintext, err := decryptAge(data)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to decrypt %s: %w", filename, err)
+		}
+		return plaintext, true, nil
+	case isSOPSEncrypted(data):
+		plaintext, err := decryptSOPS(filename)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to decrypt %s: %w", filename, err)
+		}
+		return plaintext, true, nil
+	default:
+		return data, false, nil
+	}
+}
+
+// IsEncrypted reports whether a file exists and is encrypted with age or SOPS
+func IsEncrypted(filename string) bool {
+	data, err := os.ReadFile(filename)
+	return err == nil && (isAgeEncrypted(data) || isSOPSEncrypted(data))
+}
+
+func isAgeEncrypted(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return bytes.HasPrefix(trimmed, []byte(armor.Header)) || bytes.HasPrefix(trimmed, []byte("age-encryption.org/"))
+}
+
+// isSOPSEncrypted reports whether a YAML or JSON document carries the sops
+// metadata block SOPS adds to the files it encrypts
+func isSOPSEncrypted(data []byte) bool {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return false
+	}
+
+	metadata := mappingValue(doc.Content[0], "sops")
+	return metadata != nil && metadata.Kind == yaml.MappingNode && mappingValue(metadata, "mac") != nil
+}
+
+// decryptAge decrypts an armored or binary age file with the identities SOPS
+// would use, so that one age key serves both kinds of encrypted files
+func decryptAge(data []byte) ([]byte, error) {
+	identities, err := ageIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// ageIdentities loads the age keys from SOPS_AGE_KEY, SOPS_AGE_KEY_FILE and
+// the default SOPS key file
+func ageIdentities() ([]age.Identity, error) {
+	var identities []age.Identity
+
+	if keys := os.Getenv("SOPS_AGE_KEY"); keys != "" {
+		parsed, err := age.ParseIdentities(strings.NewReader(keys))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SOPS_AGE_KEY: %w", err)
+		}
+		identities = append(identities, parsed...)
+	}
+
+	keyFile, optional := os.Getenv("SOPS_AGE_KEY_FILE"), false
+	if keyFile == "" {
+		configDir, err := os.UserConfigDir()
+		if err == nil {
+			keyFile, optional = filepath.Join(configDir, "sops", "age", "keys.txt"), true
+		}
+	}
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		switch {
+		case err == nil:
+			parsed, err := age.ParseIdentities(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse age keys %s: %w", keyFile, err)
+			}
+			identities = append(identities, parsed...)
+		case !optional || !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to read age keys: %w", err)
+		}
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no age key found (set SOPS_AGE_KEY or SOPS_AGE_KEY_FILE)")
+	}
+	return identities, nil
+}
+
+// decryptSOPS decrypts a SOPS file with the sops command, which knows about
+// all the key services SOPS supports
+func decryptSOPS(filename string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sops", "--decrypt", filename)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("file is encrypted with SOPS, install sops to decrypt it")
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("sops: %s", message)
+		}
+		return nil, fmt.Errorf("sops: %w", err)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTTLFor returns the TTL a record of the given type gets when it does
+// not set one: the type default, then the zone default, then DefaultTTL
+func (z *DNSZone) DefaultTTLFor(recordType string) int {
+	if z.Defaults != nil {
+		if typeDefaults, ok := z.Defaults.Types[strings.ToUpper(recordType)]; ok && typeDefaults.TTL > 0 {
+			return typeDefaults.TTL
+		}
+		if z.Defaults.TTL > 0 {
+			return z.Defaults.TTL
+		}
+	}
+	return DefaultTTL
+}
+
+// applyDefaults fills unset record TTLs from the defaults block
+func applyDefaults(zone *DNSZone) error {
+	if zone.Defaults == nil {
+		return nil
+	}
+
+	if err := validateDefaultTTL("defaults.ttl", zone.Defaults.TTL); err != nil {
+		return err
+	}
+	for recordType, typeDefaults := range zone.Defaults.Types {
+		if err := validateDefaultTTL("defaults.types."+recordType+".ttl", typeDefaults.TTL); err != nil {
+			return err
+		}
+	}
+
+	for i := range zone.Records {
+		if zone.Records[i].TTL == 0 {
+			zone.Records[i].TTL = zone.DefaultTTLFor(zone.Records[i].Type)
+		}
+	}
+
+	return nil
+}
+
+// normalizeTypeDefaults upper-cases the record types of defaults.types, so
+// that they match records whatever their case
+func normalizeTypeDefaults(defaults *ZoneDefaults) error {
+	if len(defaults.Types) == 0 {
+		return nil
+	}
+
+	types := make(map[string]TypeDefaults, len(defaults.Types))
+	for recordType, typeDefaults := range defaults.Types {
+		upper := strings.ToUpper(recordType)
+		if _, exists := types[upper]; exists {
+			return fmt.Errorf("defaults.types declares %s more than once", upper)
+		}
+		types[upper] = typeDefaults
+	}
+	defaults.Types = types
+	return nil
+}
+
+func validateDefaultTTL(field string, ttl int) error {
+	if ttl < 0 {
+		return fmt.Errorf("%s cannot be negative", field)
+	}
+	if ttl > MaxTTL {
+		return fmt.Errorf("%s too large (max: %d)", field, MaxTTL)
+	}
+	return nil
+}
+
+// FactorDefaults moves the most common record TTL into the defaults block and
+// clears it from the records using it, keeping exported files concise
+func FactorDefaults(zone *DNSZone) {
+	counts := make(map[int]int)
+	for _, record := range zone.Records {
+		if record.TTL > 0 {
+			counts[record.TTL]++
+		}
+	}
+
+	common, best := 0, 1
+	for ttl, count := range counts {
+		if count > best || (count == best && common != 0 && ttl < common) {
+			common, best = ttl, count
+		}
+	}
+
+	// Not worth a defaults block unless at least two records share the TTL
+	if common == 0 {
+		return
+	}
+
+	zone.Defaults = &ZoneDefaults{TTL: common}
+	for i := range zone.Records {
+		if zone.Records[i].TTL == common {
+			zone.Records[i].TTL = 0
+		}
+	}
+}
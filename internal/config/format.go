@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Zone file formats, selected by file extension
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// ZoneFormat returns the format of a zone file from its extension, ignoring
+// the .age extension of encrypted files and defaulting to YAML
+func ZoneFormat(filename string) string {
+	filename = strings.TrimSuffix(strings.ToLower(filename), ".age")
+	switch filepath.Ext(filename) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// parseZoneData parses a zone file of any format into a YAML document node,
+// so that every format goes through the same decoding. JSON is valid YAML and
+// is parsed as such, which keeps line numbers in error messages.
+func parseZoneData(filename string, data []byte) (*yaml.Node, error) {
+	if ZoneFormat(filename) == FormatTOML {
+		var raw map[string]interface{}
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML %s: %w", filename, err)
+		}
+
+		var node yaml.Node
+		if err := node.Encode(raw); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML %s: %w", filename, err)
+		}
+		return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&node}}, nil
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse %s %s: %w", strings.ToUpper(ZoneFormat(filename)), filename, err)
+	}
+
+	return &node, nil
+}
+
+// encodeZone renders a zone in the format matching filename. Group comments
+// are only supported by YAML.
+func encodeZone(zone *DNSZone, filename string, groupComments bool) ([]byte, error) {
+	switch ZoneFormat(filename) {
+	case FormatJSON:
+		data, err := json.MarshalIndent(zone, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return append(data, '\n'), nil
+	case FormatTOML:
+		var buf bytes.Buffer
+		encoder := toml.NewEncoder(&buf)
+		encoder.Indent = ""
+		if err := encoder.Encode(zone); err != nil {
+			return nil, fmt.Errorf("failed to marshal TOML: %w", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return MarshalDNSZone(zone, groupComments)
+	}
+}
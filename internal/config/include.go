@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// zoneSource is one file taking part in a zone: the file being loaded, a
+// fragment it includes or a base zone it extends. depth counts the extends
+// levels between the file and the zone being loaded; values from a lower
+// depth override those from a higher one.
+type zoneSource struct {
+	path     string
+	depth    int
+	included bool
+	zone     DNSZone
+}
+
+// definition remembers where a variable, group or record key was defined
+type definition struct {
+	path  string
+	depth int
+}
+
+// loadZone resolves the includes and extends of a zone file and returns the
+// merged, expanded and validated zone. Conflicts are resolved as follows:
+//   - a file overrides the variables, groups, defaults, dnssec setting and
+//     records (by name and type) of the zone it extends
+//   - defining the same variable, group or record in two files at the same
+//     level, such as two included fragments or a fragment and the file
+//     including it, is an error
+func loadZone(filename string, node *yaml.Node) (*DNSZone, error) {
+	var sources []zoneSource
+	if err := collectSources(filename, node, 0, false, nil, &sources); err != nil {
+		return nil, err
+	}
+
+	zone := &DNSZone{
+		Vars:   make(map[string]string),
+		Groups: make(map[string][]DNSRecord),
+	}
+	varDefs := make(map[string]definition)
+	groupDefs := make(map[string]definition)
+
+	for _, source := range sources {
+		def := definition{path: source.path, depth: source.depth}
+
+		if source.zone.Domain != "" {
+			if zone.Domain == "" {
+				zone.Domain = source.zone.Domain
+			} else if source.included && source.zone.Domain != zone.Domain && source.depth == 0 {
+				return nil, fmt.Errorf("included file %s is for domain %s, not %s", source.path, source.zone.Domain, zone.Domain)
+			}
+		}
+
+		if source.zone.DNSSEC != nil {
+			if source.included {
+				return nil, fmt.Errorf("dnssec is not allowed in included file %s", source.path)
+			}
+			if zone.DNSSEC == nil {
+				zone.DNSSEC = source.zone.DNSSEC
+			}
+		}
+
+		if source.zone.Defaults != nil {
+			if source.included {
+				return nil, fmt.Errorf("defaults are not allowed in included file %s", source.path)
+			}
+			if err := normalizeTypeDefaults(source.zone.Defaults); err != nil {
+				return nil, fmt.Errorf("invalid defaults in %s: %w", source.path, err)
+			}
+			zone.Defaults = mergeDefaults(zone.Defaults, source.zone.Defaults)
+		}
+
+		for name, value := range source.zone.Vars {
+			keep, err := override(varDefs, name, def, "variable")
+			if err != nil {
+				return nil, err
+			}
+			if !keep {
+				zone.Vars[name] = value
+			}
+		}
+
+		for name, records := range source.zone.Groups {
+			keep, err := override(groupDefs, name, def, "record group")
+			if err != nil {
+				return nil, err
+			}
+			if !keep {
+				zone.Groups[name] = records
+			}
+		}
+	}
+
+	records, err := expandSourceRecords(zone, sources)
+	if err != nil {
+		return nil, err
+	}
+	zone.Records = records
+
+	if err := prepareZone(zone); err != nil {
+		return nil, err
+	}
+
+	return zone, nil
+}
+
+// collectSources decodes a zone file and, recursively, the files it includes
+// and extends, in order of increasing depth precedence: the file itself comes
+// before its includes, which come before the zone it extends
+func collectSources(filename string, node *yaml.Node, depth int, included bool, stack []string, sources *[]zoneSource) error {
+	absolute, err := filepath.Abs(filename)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", filename, err)
+	}
+	for _, parent := range stack {
+		if parent == absolute {
+			return fmt.Errorf("zone file %s includes or extends itself", filename)
+		}
+	}
+	stack = append(stack, absolute)
+
+	if err := checkZoneNode(filename, node); err != nil {
+		return err
+	}
+
+	var zone DNSZone
+	if err := node.Decode(&zone); err != nil {
+		return fmt.Errorf("failed to decode zone file %s: %w", filename, err)
+	}
+
+	if included && zone.Extends != "" {
+		return fmt.Errorf("included file %s cannot extend another zone", filename)
+	}
+
+	*sources = append(*sources, zoneSource{
+		path:     filename,
+		depth:    depth,
+		included: included,
+		zone:     zone,
+	})
+
+	for _, include := range zone.Include {
+		path := relativeTo(filename, include)
+		child, err := readZoneNode(path)
+		if err != nil {
+			return err
+		}
+		if err := collectSources(path, child, depth, true, stack, sources); err != nil {
+			return err
+		}
+	}
+
+	if zone.Extends != "" {
+		path := relativeTo(filename, zone.Extends)
+		base, err := readZoneNode(path)
+		if err != nil {
+			return err
+		}
+		if err := collectSources(path, base, depth+1, false, stack, sources); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandSourceRecords expands the records of every source with the merged
+// variables and groups, drops records overridden by a lower depth and
+// rejects records defined by two files at the same depth
+func expandSourceRecords(zone *DNSZone, sources []zoneSource) ([]DNSRecord, error) {
+	type sourcedRecord struct {
+		record DNSRecord
+		def    definition
+	}
+
+	var expanded []sourcedRecord
+	keyDefs := make(map[string]definition)
+
+	for _, source := range sources {
+		def := definition{path: source.path, depth: source.depth}
+		for i, entry := range source.zone.Records {
+			records, err := zone.expandRecord(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DNS record %d in %s: %w", i, source.path, err)
+			}
+
+			for _, record := range records {
+				key := recordKey(&record)
+				existing, exists := keyDefs[key]
+				switch {
+				case !exists || def.depth < existing.depth:
+					keyDefs[key] = def
+				case def.depth == existing.depth && def.path != existing.path:
+					name := record.Name
+					if IsApex(name) {
+						name = "@"
+					}
+					return nil, fmt.Errorf("record %s %s is defined in both %s and %s, declare it in only one of them", name, record.Type, existing.path, def.path)
+				}
+				expanded = append(expanded, sourcedRecord{record: record, def: def})
+			}
+		}
+	}
+
+	// Keep the records of the file defining each key with the most precedence,
+	// listing the records of base zones first
+	var records []DNSRecord
+	for depth := maxDepth(sources); depth >= 0; depth-- {
+		for _, entry := range expanded {
+			if entry.def.depth == depth && keyDefs[recordKey(&entry.record)].depth == depth {
+				records = append(records, entry.record)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// override records the definition of name and reports whether an existing
+// definition with more precedence must be kept instead
+func override(defs map[string]definition, name string, def definition, kind string) (bool, error) {
+	existing, exists := defs[name]
+	switch {
+	case !exists || def.depth < existing.depth:
+		defs[name] = def
+		return false, nil
+	case def.depth == existing.depth && def.path != existing.path:
+		return false, fmt.Errorf("%s %s is defined in both %s and %s", kind, name, existing.path, def.path)
+	default:
+		return true, nil
+	}
+}
+
+// mergeDefaults fills the unset values of current, which has precedence,
+// from base
+func mergeDefaults(current, base *ZoneDefaults) *ZoneDefaults {
+	if current == nil {
+		copied := *base
+		return &copied
+	}
+
+	if current.TTL == 0 {
+		current.TTL = base.TTL
+	}
+	for recordType, typeDefaults := range base.Types {
+		if current.Types == nil {
+			current.Types = make(map[string]TypeDefaults)
+		}
+		if _, ok := current.Types[recordType]; !ok {
+			current.Types[recordType] = typeDefaults
+		}
+	}
+
+	return current
+}
+
+// recordKey identifies records by name and type, treating the apex spellings
+// alike as ovh.RecordKey does
+func recordKey(record *DNSRecord) string {
+	name := record.Name
+	if IsApex(name) {
+		name = ""
+	}
+	return name + ":" + record.Type
+}
+
+func relativeTo(filename, ref string) string {
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(filepath.Dir(filename), ref)
+}
+
+func maxDepth(sources []zoneSource) int {
+	depth := 0
+	for _, source := range sources {
+		if source.depth > depth {
+			depth = source.depth
+		}
+	}
+	return depth
+}
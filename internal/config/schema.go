@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaID identifies the zone file schema
+const SchemaID = "https://github.com/epheo/ovh-dns-manager/zone.schema.json"
+
+// RecordTypes lists the record types supported in zone files
+var RecordTypes = []string{"A", "AAAA", "CNAME", "TXT", "NS", "SPF", "CAA", "PTR", "MX", "SRV"}
+
+// schemaHints refines the schema generated for some fields, keyed by
+// struct name and field name
+var schemaHints = map[string]map[string]interface{}{
+	"DNSZone.Schema":     {"description": "JSON Schema of the file, ignored by the loader"},
+	"DNSZone.Strict":     {"description": "Reject unknown fields, true by default"},
+	"DNSZone.Domain":     {"description": "Domain name of the OVH zone"},
+	"DNSZone.DNSSEC":     {"description": "Enable or disable DNSSEC signing of the zone, left unchanged when unset"},
+	"DNSZone.Extends":    {"description": "Zone file this file overrides, relative to this file"},
+	"DNSZone.Include":    {"description": "Zone file fragments merged into this file, relative to this file"},
+	"DNSZone.Defaults":   {"description": "Values applied to records that leave them unset"},
+	"DNSZone.Vars":       {"description": "Variables referenced as ${NAME} in record names and targets"},
+	"DNSZone.Groups":     {"description": "Reusable lists of records, expanded by records with a group field"},
+	"ZoneDefaults.TTL":   {"description": "Default TTL of all records", "minimum": 0, "maximum": MaxTTL},
+	"ZoneDefaults.Types": {"description": "Defaults per record type", "propertyNames": map[string]interface{}{"enum": RecordTypes}},
+	"TypeDefaults.TTL":   {"description": "Default TTL of the records of this type", "minimum": 0, "maximum": MaxTTL},
+	"DNSRecord.Name":     {"description": "Subdomain of the record, empty or @ for the apex"},
+	"DNSRecord.Type":     {"enum": RecordTypes},
+	"DNSRecord.Target":   {"description": "Value of the record"},
+	"DNSRecord.TTL":      {"description": "TTL in seconds, 0 to use the defaults", "minimum": 0, "maximum": MaxTTL},
+	"DNSRecord.Priority": {"description": "Priority of MX and SRV records", "minimum": 0},
+	"DNSRecord.Group":    {"description": "Record group to expand under this name"},
+}
+
+// schemaRequirements lists alternative sets of required properties per struct
+var schemaRequirements = map[string][][]string{
+	"DNSRecord": {{"group"}, {"type", "target"}},
+}
+
+// GenerateJSONSchema returns the JSON Schema of zone files, derived from the
+// DNSZone type so that it follows the fields the loader accepts
+func GenerateJSONSchema() ([]byte, error) {
+	defs := make(map[string]interface{})
+	root := schemaForStruct(reflect.TypeOf(DNSZone{}), defs)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SchemaID
+	root["title"] = "OVH DNS Manager zone file"
+	root["$defs"] = defs
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaForType returns the schema of a Go type, adding the structs it
+// refers to under defs
+func schemaForType(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaForType(t.Elem(), defs)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaForType(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // reserve the name for recursive types
+			defs[t.Name()] = schemaForStruct(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// schemaForStruct returns the object schema of a struct from its yaml tags
+func schemaForStruct(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		property := schemaForType(field.Type, defs)
+		for key, value := range schemaHints[t.Name()+"."+field.Name] {
+			property[key] = value
+		}
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if alternatives := schemaRequirements[t.Name()]; len(alternatives) > 0 {
+		var anyOf []interface{}
+		for _, required := range alternatives {
+			anyOf = append(anyOf, map[string]interface{}{"required": required})
+		}
+		schema["anyOf"] = anyOf
+	}
+
+	return schema
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// IsApex reports whether a record name refers to the zone apex
+func IsApex(name string) bool {
+	return name == "" || name == "@"
+}
+
+// ACMEChallengeLabel is the label under which DNS-01 challenges are published
+const ACMEChallengeLabel = "_acme-challenge"
+
+// IsACMEChallenge reports whether a record holds a DNS-01 challenge
+func IsACMEChallenge(subDomain, fieldType string) bool {
+	return fieldType == "TXT" &&
+		(subDomain == ACMEChallengeLabel || strings.HasPrefix(subDomain, ACMEChallengeLabel+"."))
+}
+
+// SortRecords orders records so that exports are stable across runs:
+// apex records first, then by name, type, priority and target
+func SortRecords(records []DNSRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return recordLess(&records[i], &records[j])
+	})
+}
+
+func recordLess(a, b *DNSRecord) bool {
+	if apexA, apexB := IsApex(a.Name), IsApex(b.Name); apexA != apexB {
+		return apexA
+	}
+	if a.Name != b.Name {
+		if la, lb := strings.ToLower(a.Name), strings.ToLower(b.Name); la != lb {
+			return la < lb
+		}
+		return a.Name < b.Name
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	return a.Target < b.Target
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkZoneNode rejects duplicate keys and, unless the file sets
+// strict: false, fields DNSZone does not know about, which would otherwise be
+// silently ignored. Every problem found is reported with its position.
+func checkZoneNode(filename string, doc *yaml.Node) error {
+	root := doc
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil
+		}
+		root = root.Content[0]
+	}
+
+	checker := &fieldChecker{filename: filename, strict: true}
+	if value := mappingValue(root, "strict"); value != nil {
+		if err := value.Decode(&checker.strict); err != nil {
+			return fmt.Errorf("%s: strict must be true or false", checker.position(value))
+		}
+	}
+
+	checker.check(root, reflect.TypeOf(DNSZone{}), "")
+	return errors.Join(checker.problems...)
+}
+
+type fieldChecker struct {
+	filename string
+	strict   bool
+	problems []error
+}
+
+func (c *fieldChecker) check(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := structFields(t)
+		c.eachKey(node, path, func(key, value *yaml.Node) {
+			field, ok := fields[key.Value]
+			if !ok {
+				if c.strict {
+					c.unknownField(key, path, fields)
+				}
+				return
+			}
+			c.check(value, field.Type, joinPath(path, key.Value))
+		})
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		c.eachKey(node, path, func(key, value *yaml.Node) {
+			c.check(value, t.Elem(), joinPath(path, key.Value))
+		})
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			c.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// eachKey calls fn for every key of a mapping, reporting duplicate keys
+// instead of passing them on
+func (c *fieldChecker) eachKey(node *yaml.Node, path string, fn func(key, value *yaml.Node)) {
+	seen := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag == "!!merge" {
+			continue
+		}
+
+		if first, ok := seen[key.Value]; ok {
+			problem := fmt.Sprintf("duplicate key %q%s", key.Value, in(path))
+			if first.Line > 0 {
+				problem += fmt.Sprintf(", first defined at line %d", first.Line)
+			}
+			c.problems = append(c.problems, fmt.Errorf("%s: %s", c.position(key), problem))
+			continue
+		}
+		seen[key.Value] = key
+
+		fn(key, value)
+	}
+}
+
+func (c *fieldChecker) unknownField(key *yaml.Node, path string, fields map[string]reflect.StructField) {
+	problem := fmt.Sprintf("unknown field %q%s", key.Value, in(path))
+	if suggestion := closestField(key.Value, fields); suggestion != "" {
+		problem += fmt.Sprintf(" (did you mean %q?)", suggestion)
+	}
+	c.problems = append(c.problems, fmt.Errorf("%s: %s", c.position(key), problem))
+}
+
+// position formats the location of a node as file:line:column, or as the
+// file name alone for formats without positions such as TOML
+func (c *fieldChecker) position(node *yaml.Node) string {
+	if node.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d", c.filename, node.Line, node.Column)
+	}
+	return c.filename
+}
+
+// structFields maps the yaml names of the fields of a struct to the fields
+func structFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// closestField returns the known field closest to a misspelled one, if any
+// is within two edits
+func closestField(name string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", 3
+	for field := range fields {
+		if distance := editDistance(strings.ToLower(name), field); distance < bestDistance || (distance == bestDistance && field < best) {
+			best, bestDistance = field, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func in(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " in " + path
+}
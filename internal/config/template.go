@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// ErrTemplated is returned when editing a record of a zone file that is
+// produced by variables, a record group or another zone file rather than
+// written literally
+var ErrTemplated = errors.New("record is generated from variables, a record group or another zone file")
+
+// variablePattern matches ${NAME} references and the $${ escape
+var variablePattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandRecord returns the records a zone file entry stands for
+func (z *DNSZone) expandRecord(record DNSRecord) ([]DNSRecord, error) {
+	if record.Group == "" {
+		expanded, err := z.expandVars(record)
+		if err != nil {
+			return nil, err
+		}
+		return []DNSRecord{expanded}, nil
+	}
+
+	if record.Type != "" || record.Target != "" || record.Priority != 0 {
+		return nil, fmt.Errorf("group entry %s only accepts name and ttl", record.Group)
+	}
+
+	group, ok := z.Groups[record.Group]
+	if !ok {
+		return nil, fmt.Errorf("unknown record group %s", record.Group)
+	}
+
+	records := make([]DNSRecord, 0, len(group))
+	for _, member := range group {
+		if member.Group != "" {
+			return nil, fmt.Errorf("record group %s cannot include group %s", record.Group, member.Group)
+		}
+
+		// Group members are relative to the name of the entry using the group
+		if !IsApex(record.Name) {
+			if IsApex(member.Name) {
+				member.Name = record.Name
+			} else {
+				member.Name = member.Name + "." + record.Name
+			}
+		}
+		if member.TTL == 0 {
+			member.TTL = record.TTL
+		}
+
+		expanded, err := z.expandVars(member)
+		if err != nil {
+			return nil, fmt.Errorf("record group %s: %w", record.Group, err)
+		}
+		records = append(records, expanded)
+	}
+
+	return records, nil
+}
+
+func (z *DNSZone) expandVars(record DNSRecord) (DNSRecord, error) {
+	var err error
+	if record.Name, err = z.expandString(record.Name); err != nil {
+		return record, err
+	}
+	if record.Target, err = z.expandString(record.Target); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// expandString resolves ${NAME} references in value
+func (z *DNSZone) expandString(value string) (string, error) {
+	var missing []string
+	expanded := variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+
+		name := match[2 : len(match)-1]
+		if v, ok := z.Vars[name]; ok {
+			return v
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+
+		missing = append(missing, name)
+		return match
+	})
+
+	if len(missing) > 0 {
+		return value, fmt.Errorf("undefined variable %s (define it in vars or the environment)", missing[0])
+	}
+	return expanded, nil
+}
+
+// isTemplated reports whether an entry uses variables or a record group
+func isTemplated(record *DNSRecord) bool {
+	return record.Group != "" ||
+		variablePattern.MatchString(record.Name) ||
+		variablePattern.MatchString(record.Target)
+}
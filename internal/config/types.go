@@ -1,16 +1,43 @@
 package config
 
+import "time"
+
 type DNSZone struct {
-	Domain  string      `yaml:"domain"`
-	Records []DNSRecord `yaml:"records"`
+	// Schema points editors to the JSON Schema of zone files
+	Schema string `yaml:"$schema,omitempty" json:"$schema,omitempty" toml:"$schema,omitempty"`
+	// Strict set to false accepts unknown fields instead of rejecting them
+	Strict *bool  `yaml:"strict,omitempty" json:"strict,omitempty" toml:"strict,omitempty"`
+	Domain string `yaml:"domain" json:"domain,omitempty" toml:"domain,omitempty"`
+	// DNSSEC, when set, enables or disables DNSSEC signing of the zone
+	DNSSEC   *bool                  `yaml:"dnssec,omitempty" json:"dnssec,omitempty" toml:"dnssec,omitempty"`
+	Extends  string                 `yaml:"extends,omitempty" json:"extends,omitempty" toml:"extends,omitempty"`
+	Include  []string               `yaml:"include,omitempty" json:"include,omitempty" toml:"include,omitempty"`
+	Defaults *ZoneDefaults          `yaml:"defaults,omitempty" json:"defaults,omitempty" toml:"defaults,omitempty"`
+	Vars     map[string]string      `yaml:"vars,omitempty" json:"vars,omitempty" toml:"vars,omitempty"`
+	Groups   map[string][]DNSRecord `yaml:"groups,omitempty" json:"groups,omitempty" toml:"groups,omitempty"`
+	Records  []DNSRecord            `yaml:"records" json:"records" toml:"records"`
+}
+
+// ZoneDefaults holds values applied to records that leave them unset
+type ZoneDefaults struct {
+	TTL   int                     `yaml:"ttl,omitempty" json:"ttl,omitempty" toml:"ttl,omitzero"`
+	Types map[string]TypeDefaults `yaml:"types,omitempty" json:"types,omitempty" toml:"types,omitempty"`
+}
+
+// TypeDefaults holds defaults for the records of one type, taking
+// precedence over the zone-wide defaults
+type TypeDefaults struct {
+	TTL int `yaml:"ttl,omitempty" json:"ttl,omitempty" toml:"ttl,omitzero"`
 }
 
 type DNSRecord struct {
-	Name     string `yaml:"name"`
-	Type     string `yaml:"type"`
-	Target   string `yaml:"target"`
-	TTL      int    `yaml:"ttl,omitempty"`
-	Priority int    `yaml:"priority,omitempty"`
+	Name     string `yaml:"name" json:"name" toml:"name"`
+	Type     string `yaml:"type" json:"type,omitempty" toml:"type,omitempty"`
+	Target   string `yaml:"target" json:"target,omitempty" toml:"target,omitempty"`
+	TTL      int    `yaml:"ttl,omitempty" json:"ttl,omitempty" toml:"ttl,omitzero"`
+	Priority int    `yaml:"priority,omitempty" json:"priority,omitempty" toml:"priority,omitzero"`
+	// Group expands to the records of a named record group instead
+	Group string `yaml:"group,omitempty" json:"group,omitempty" toml:"group,omitempty"`
 }
 
 type OVHRecord struct {
@@ -42,5 +69,75 @@ type OVHCredentials struct {
 	ApplicationKey   string `yaml:"application_key"`
 	ApplicationSecret string `yaml:"application_secret"`
 	ConsumerKey      string `yaml:"consumer_key"`
-	Timeout          int    `yaml:"timeout"`
-}
\ No newline at end of file
+	// ClientID and ClientSecret select OAuth2 authentication with a service
+	// account instead of the application and consumer keys
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	TokenURL     string `yaml:"token_url"`
+	Timeout      int    `yaml:"timeout"`
+}
+
+type OVHDynHostRecord struct {
+	ID        int64  `json:"id,omitempty"`
+	Zone      string `json:"zone,omitempty"`
+	SubDomain string `json:"subDomain"`
+	IP        string `json:"ip"`
+	TTL       int    `json:"ttl,omitempty"`
+}
+
+type OVHDynHostLogin struct {
+	Login     string `json:"login"`
+	SubDomain string `json:"subDomain"`
+	Zone      string `json:"zone"`
+}
+
+type OVHZone struct {
+	Name            string   `json:"name"`
+	NameServers     []string `json:"nameServers"`
+	DNSSECSupported bool     `json:"dnssecSupported"`
+	HasDNSAnycast   bool     `json:"hasDnsAnycast"`
+}
+
+// OVHDNSSEC is the DNSSEC signing state of a zone
+type OVHDNSSEC struct {
+	Status string `json:"status"`
+}
+
+// OVHAccessRule grants a consumer key one HTTP method on an API path, where
+// a trailing * matches any suffix
+type OVHAccessRule struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+type OVHCredentialRequest struct {
+	AccessRules []OVHAccessRule `json:"accessRules"`
+	Redirection string          `json:"redirection,omitempty"`
+}
+
+// OVHCredentialToken is a consumer key waiting for the account owner to
+// validate it at ValidationURL
+type OVHCredentialToken struct {
+	ValidationURL string `json:"validationUrl"`
+	ConsumerKey   string `json:"consumerKey"`
+	State         string `json:"state"`
+}
+
+type OVHCurrentCredential struct {
+	CredentialID  int64           `json:"credentialId"`
+	ApplicationID int64           `json:"applicationId"`
+	Status        string          `json:"status"`
+	Creation      *time.Time      `json:"creation"`
+	Expiration    *time.Time      `json:"expiration"`
+	LastUse       *time.Time      `json:"lastUse"`
+	Rules         []OVHAccessRule `json:"rules"`
+}
+
+// OVHAccount is the account a consumer key acts for
+type OVHAccount struct {
+	Nichandle    string `json:"nichandle"`
+	Email        string `json:"email"`
+	FirstName    string `json:"firstname"`
+	Name         string `json:"name"`
+	Organisation string `json:"organisation"`
+}
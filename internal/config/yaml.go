@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"strconv"
@@ -14,84 +15,164 @@ const (
 	MaxTTL     = 2147483647 // Maximum TTL value (2^31-1)
 )
 
+// LoadDNSZone reads a zone file along with the files it includes or extends,
+// expands variables and record groups, applies defaults and validates the
+// resulting records
 func LoadDNSZone(filename string) (*DNSZone, error) {
-	data, err := os.ReadFile(filename)
+	node, err := readZoneNode(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadZone(filename, node)
+}
+
+// readZoneNode reads, decrypts when needed, and parses a zone file of any
+// format into a document node
+func readZoneNode(filename string) (*yaml.Node, error) {
+	data, _, err := readFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
 	}
 
-	var zone DNSZone
-	if err := yaml.Unmarshal(data, &zone); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	return parseZoneData(filename, data)
+}
+
+// prepareZone applies the defaults block and validates all DNS records of a
+// loaded zone
+func prepareZone(zone *DNSZone) error {
+	if err := applyDefaults(zone); err != nil {
+		return err
 	}
 
-	// Validate all DNS records during loading
 	for i := range zone.Records {
 		if err := ValidateDNSRecord(&zone.Records[i]); err != nil {
-			return nil, fmt.Errorf("invalid DNS record %d: %w", i, err)
+			return fmt.Errorf("invalid DNS record %d: %w", i, err)
 		}
 	}
-
-	return &zone, nil
+	return nil
 }
 
-func SaveDNSZone(zone *DNSZone, filename string) error {
-	data, err := yaml.Marshal(zone)
+// SaveDNSZone writes a zone to a file in the format matching its extension
+// (YAML, JSON or TOML), YAML using a stable two-space layout. When
+// groupComments is set, each group of YAML records sharing a name is preceded
+// by a comment carrying that name.
+func SaveDNSZone(zone *DNSZone, filename string, groupComments bool) error {
+	data, err := encodeZone(zone, filename, groupComments)
 	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
+		return err
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	// Zone files may hold sensitive TXT values; WriteFile only applies the
+	// mode to new files
+	if err := os.WriteFile(filename, data, 0600); err != nil {
 		return fmt.Errorf("failed to write file %s: %w", filename, err)
 	}
 
 	return nil
 }
 
-func LoadOVHCredentials(filename string) (*OVHCredentials, error) {
+// MarshalDNSZone renders a zone as YAML, see SaveDNSZone
+func MarshalDNSZone(zone *DNSZone, groupComments bool) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(zone); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	if groupComments {
+		addGroupComments(&node)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// addGroupComments adds a head comment before the first record of each name
+func addGroupComments(zoneNode *yaml.Node) {
+	records := mappingValue(zoneNode, "records")
+	if records == nil || records.Kind != yaml.SequenceNode {
+		return
+	}
+
+	previous := ""
+	for i, item := range records.Content {
+		name := ""
+		if value := mappingValue(item, "name"); value != nil {
+			name = value.Value
+		}
+		if i == 0 || name != previous {
+			if IsApex(name) {
+				item.HeadComment = "@ (apex)"
+			} else {
+				item.HeadComment = name
+			}
+		}
+		previous = name
+	}
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// LoadOVHCredentials resolves the OVH API credentials of a profile, or of the
+// default account when profile and OVH_PROFILE are empty. Each value comes
+// from the first source setting it: environment variables, the YAML
+// credentials file, the ovh.conf files shared with other OVH tools, then
+// built-in defaults.
+func LoadOVHCredentials(filename, profile string) (*OVHCredentials, error) {
 	var creds OVHCredentials
-	
+
+	if profile == "" {
+		profile = getEnvOrDefault("OVH_PROFILE", "")
+	}
+
 	// First, try to load from environment variables
 	creds.Endpoint = getEnvOrDefault("OVH_ENDPOINT", "")
 	creds.ApplicationKey = getEnvOrDefault("OVH_APPLICATION_KEY", "")
 	creds.ApplicationSecret = getEnvOrDefault("OVH_APPLICATION_SECRET", "")
 	creds.ConsumerKey = getEnvOrDefault("OVH_CONSUMER_KEY", "")
+	creds.ClientID = getEnvOrDefault("OVH_CLIENT_ID", "")
+	creds.ClientSecret = getEnvOrDefault("OVH_CLIENT_SECRET", "")
+	creds.TokenURL = getEnvOrDefault("OVH_TOKEN_URL", "")
 	creds.Timeout = getEnvIntOrDefault("OVH_TIMEOUT", 0)
 
-	// Check if we have all required credentials from environment
-	hasEnvCreds := creds.ApplicationKey != "" && creds.ApplicationSecret != "" && creds.ConsumerKey != ""
-	
-	// If not all credentials from env, try to load from file
-	if !hasEnvCreds {
-		if filename == "" {
-			filename = "ovh-credentials.yaml"
-		}
-
-		data, err := os.ReadFile(filename)
+	// If not all credentials from env, try the credentials file, then ovh.conf
+	if !creds.complete() {
+		fileCreds, fileHasProfile, err := loadCredentialsFile(filename, profile, credentialsFileName(filename) == DefaultCredentialsFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read credentials file %s and no environment variables set: %w", filename, err)
-		}
-		
-		var fileCreds OVHCredentials
-		if err := yaml.Unmarshal(data, &fileCreds); err != nil {
-			return nil, fmt.Errorf("failed to parse credentials YAML: %w", err)
+			return nil, err
 		}
-		
-		// Use file values only if env vars are not set
-		if creds.Endpoint == "" {
-			creds.Endpoint = fileCreds.Endpoint
-		}
-		if creds.ApplicationKey == "" {
-			creds.ApplicationKey = fileCreds.ApplicationKey
-		}
-		if creds.ApplicationSecret == "" {
-			creds.ApplicationSecret = fileCreds.ApplicationSecret
-		}
-		if creds.ConsumerKey == "" {
-			creds.ConsumerKey = fileCreds.ConsumerKey
-		}
-		if creds.Timeout == 0 {
-			creds.Timeout = fileCreds.Timeout
+		creds.fillFrom(fileCreds)
+
+		if !creds.complete() {
+			confCreds, confHasProfile, err := loadOVHConf(profile, creds.Endpoint)
+			if err != nil {
+				return nil, err
+			}
+			creds.fillFrom(confCreds)
+
+			if profile != "" && !fileHasProfile && !confHasProfile {
+				return nil, fmt.Errorf("credentials profile %s not found in %s or ovh.conf", profile, credentialsFileName(filename))
+			}
 		}
 	}
 
@@ -103,15 +184,29 @@ func LoadOVHCredentials(filename string) (*OVHCredentials, error) {
 		creds.Timeout = 30
 	}
 
+	// Read values given as file:, exec: or env: references
+	if err := creds.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
 	// Validate required fields
+	if creds.UsesOAuth2() {
+		if creds.ClientID == "" {
+			return nil, fmt.Errorf("client_id is required with client_secret (set OVH_CLIENT_ID env var or provide in credentials file or ovh.conf)")
+		}
+		if creds.ClientSecret == "" {
+			return nil, fmt.Errorf("client_secret is required with client_id (set OVH_CLIENT_SECRET env var or provide in credentials file or ovh.conf)")
+		}
+		return &creds, nil
+	}
 	if creds.ApplicationKey == "" {
-		return nil, fmt.Errorf("application_key is required (set OVH_APPLICATION_KEY env var or provide in credentials file)")
+		return nil, fmt.Errorf("application_key is required (set OVH_APPLICATION_KEY env var or provide in credentials file or ovh.conf)")
 	}
 	if creds.ApplicationSecret == "" {
-		return nil, fmt.Errorf("application_secret is required (set OVH_APPLICATION_SECRET env var or provide in credentials file)")
+		return nil, fmt.Errorf("application_secret is required (set OVH_APPLICATION_SECRET env var or provide in credentials file or ovh.conf)")
 	}
 	if creds.ConsumerKey == "" {
-		return nil, fmt.Errorf("consumer_key is required (set OVH_CONSUMER_KEY env var or provide in credentials file)")
+		return nil, fmt.Errorf("consumer_key is required (set OVH_CONSUMER_KEY env var or provide in credentials file or ovh.conf)")
 	}
 
 	return &creds, nil
@@ -142,6 +237,11 @@ func ValidateDNSRecord(record *DNSRecord) error {
 		return fmt.Errorf("unsupported record type: %s", record.Type)
 	}
 
+	// Challenges come and go with the acme command, apply leaves them alone
+	if IsACMEChallenge(record.Name, record.Type) {
+		return fmt.Errorf("%s TXT records are managed by the acme command and cannot be declared", record.Name)
+	}
+
 	if record.TTL < 0 {
 		return fmt.Errorf("TTL cannot be negative")
 	}
@@ -174,7 +274,7 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 
 // LoadAppConfig loads application configuration from environment variables with fallbacks
 func LoadAppConfig() (credentialsPath, domain, configPath string) {
-	credentialsPath = getEnvOrDefault("OVH_CREDENTIALS_PATH", "ovh-credentials.yaml")
+	credentialsPath = getEnvOrDefault("OVH_CREDENTIALS_PATH", DefaultCredentialsFile)
 	domain = getEnvOrDefault("OVH_DOMAIN", "")
 	configPath = getEnvOrDefault("OVH_CONFIG_PATH", "")
 	return
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ZoneFile is a zone YAML document kept as a yaml.v3 node tree. Commands that
+// rewrite a zone file edit the tree in place so that comments, record order
+// and formatting of the original file survive the round trip.
+type ZoneFile struct {
+	path   string
+	doc    *yaml.Node
+	indent int
+}
+
+// OpenZoneFile parses a zone file for in-place editing. JSON and TOML files
+// are edited the same way but rewritten in their own format, which has no
+// comments to preserve.
+func OpenZoneFile(filename string) (*ZoneFile, error) {
+	data, encrypted, err := readFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+	if encrypted {
+		return nil, fmt.Errorf("zone file %s is encrypted and cannot be rewritten, edit it with sops or age instead", filename)
+	}
+
+	doc, err := parseZoneData(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("zone file %s must contain a mapping", filename)
+	}
+
+	return &ZoneFile{
+		path:   filename,
+		doc:    doc,
+		indent: detectIndent(data),
+	}, nil
+}
+
+// Path returns the file the zone was read from
+func (f *ZoneFile) Path() string {
+	return f.path
+}
+
+// Zone resolves the current content of the document like LoadDNSZone
+func (f *ZoneFile) Zone() (*DNSZone, error) {
+	return loadZone(f.path, f.doc)
+}
+
+// SetRecord updates the record with the same name and type in place, or
+// appends it to the records list. It reports whether a record was added.
+func (f *ZoneFile) SetRecord(record *DNSRecord) (bool, error) {
+	if err := ValidateDNSRecord(record); err != nil {
+		return false, err
+	}
+
+	if f.templated(record.Name, record.Type) {
+		return false, fmt.Errorf("cannot update %s %s in %s: %w", record.Name, record.Type, f.path, ErrTemplated)
+	}
+
+	records, err := f.recordsNode()
+	if err != nil {
+		return false, err
+	}
+
+	// Leave the TTL to the defaults when it matches them, unless the record
+	// already spells it out
+	entry := *record
+	if entry.TTL == f.defaultTTLFor(entry.Type) {
+		entry.TTL = 0
+	}
+
+	if item := findRecordNode(records, record.Name, record.Type); item != nil {
+		if entry.TTL == 0 && record.TTL != 0 && mappingValue(item, "ttl") != nil {
+			entry.TTL = record.TTL
+		}
+		// Keep the apex spelling of the file
+		if value := mappingValue(item, "name"); value != nil && IsApex(entry.Name) {
+			entry.Name = value.Value
+		}
+		return false, setRecordFields(item, &entry)
+	}
+
+	var item yaml.Node
+	if err := item.Encode(&entry); err != nil {
+		return false, fmt.Errorf("failed to encode record: %w", err)
+	}
+	records.Content = append(records.Content, &item)
+
+	return true, nil
+}
+
+// RemoveRecord deletes the records with the given name and type, restricted
+// to a given target when target is not empty. It reports whether a record was
+// found.
+func (f *ZoneFile) RemoveRecord(name, recordType, target string) (bool, error) {
+	if f.templated(name, recordType) {
+		return false, fmt.Errorf("cannot remove %s %s from %s: %w", name, recordType, f.path, ErrTemplated)
+	}
+
+	records, err := f.recordsNode()
+	if err != nil {
+		return false, err
+	}
+
+	kept := records.Content[:0]
+	removed := false
+	for _, item := range records.Content {
+		if recordNodeMatches(item, name, recordType) {
+			if value := mappingValue(item, "target"); target == "" || (value != nil && value.Value == target) {
+				removed = true
+				continue
+			}
+		}
+		kept = append(kept, item)
+	}
+	records.Content = kept
+
+	return removed, nil
+}
+
+// AnnotateRecord sets the trailing comment of the record with the given name
+// and type. It reports whether a record was found.
+func (f *ZoneFile) AnnotateRecord(name, recordType, comment string) bool {
+	records, err := f.recordsNode()
+	if err != nil {
+		return false
+	}
+
+	item := findRecordNode(records, name, recordType)
+	if item == nil || len(item.Content) < 2 {
+		return false
+	}
+
+	// Attach the comment to the first value so it renders on the "- name:" line
+	item.Content[1].LineComment = "# " + comment
+	return true
+}
+
+// SetDNSSEC sets the dnssec setting of the document, adding it after the
+// domain when the document does not declare it
+func (f *ZoneFile) SetDNSSEC(enabled bool) error {
+	root := f.doc.Content[0]
+	if mappingValue(root, "dnssec") == nil {
+		at := 0
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == "domain" {
+				at = i + 2
+			}
+		}
+		entry := []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "dnssec"},
+			{Kind: yaml.ScalarNode, Tag: "!!bool"},
+		}
+		root.Content = append(root.Content[:at], append(entry, root.Content[at:]...)...)
+	}
+
+	return setMappingValue(root, "dnssec", enabled)
+}
+
+// RecordComment returns the trailing comment set by AnnotateRecord on the
+// record with the given name and type, without the leading "# "
+func (f *ZoneFile) RecordComment(name, recordType string) string {
+	records, err := f.recordsNode()
+	if err != nil {
+		return ""
+	}
+
+	item := findRecordNode(records, name, recordType)
+	if item == nil || len(item.Content) < 2 {
+		return ""
+	}
+
+	return strings.TrimPrefix(item.Content[1].LineComment, "# ")
+}
+
+// Save writes the document back to the file it was read from
+func (f *ZoneFile) Save() error {
+	return f.SaveAs(f.path)
+}
+
+// SaveAs writes the document to filename, keeping the permissions of an
+// existing file and making new files only readable by their owner
+func (f *ZoneFile) SaveAs(filename string) error {
+	data, err := f.encode(filename)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err := os.WriteFile(filename, data, mode); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// encode renders the document in the format matching filename
+func (f *ZoneFile) encode(filename string) ([]byte, error) {
+	if ZoneFormat(filename) != FormatYAML {
+		var raw DNSZone
+		if err := f.doc.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to decode zone: %w", err)
+		}
+		return encodeZone(&raw, filename, false)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(f.indent)
+	if err := encoder.Encode(f.doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// defaultTTLFor returns the TTL records of a type get from the defaults of
+// the zone, including the defaults inherited from an extended file
+func (f *ZoneFile) defaultTTLFor(recordType string) int {
+	zone, err := f.Zone()
+	if err != nil {
+		return DefaultTTL
+	}
+	return zone.DefaultTTLFor(recordType)
+}
+
+// templated reports whether the record with the given name and type is
+// produced by variables, a record group, or a file included or extended by
+// the document rather than by a literal entry of the document
+func (f *ZoneFile) templated(name, recordType string) bool {
+	var raw DNSZone
+	if err := f.doc.Decode(&raw); err != nil {
+		return false
+	}
+
+	for _, entry := range raw.Records {
+		if !isTemplated(&entry) && sameRecordKey(entry.Name, entry.Type, name, recordType) {
+			return false
+		}
+	}
+
+	zone, err := f.Zone()
+	if err != nil {
+		return false
+	}
+
+	for _, record := range zone.Records {
+		if sameRecordKey(record.Name, record.Type, name, recordType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordsNode returns the records sequence, creating it when missing
+func (f *ZoneFile) recordsNode() (*yaml.Node, error) {
+	root := f.doc.Content[0]
+	if records := mappingValue(root, "records"); records != nil {
+		if records.Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("records must be a list in %s", f.path)
+		}
+		return records, nil
+	}
+
+	records := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	root.Content = append(root.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "records"},
+		records,
+	)
+	return records, nil
+}
+
+func findRecordNode(records *yaml.Node, name, recordType string) *yaml.Node {
+	for _, item := range records.Content {
+		if recordNodeMatches(item, name, recordType) {
+			return item
+		}
+	}
+	return nil
+}
+
+func recordNodeMatches(item *yaml.Node, name, recordType string) bool {
+	if item.Kind != yaml.MappingNode {
+		return false
+	}
+
+	itemName, itemType := "", ""
+	if value := mappingValue(item, "name"); value != nil {
+		itemName = value.Value
+	}
+	if value := mappingValue(item, "type"); value != nil {
+		itemType = value.Value
+	}
+
+	return sameRecordKey(itemName, itemType, name, recordType)
+}
+
+func sameRecordKey(nameA, typeA, nameB, typeB string) bool {
+	sameName := nameA == nameB || (IsApex(nameA) && IsApex(nameB))
+	return sameName && strings.EqualFold(typeA, typeB)
+}
+
+// setRecordFields rewrites the fields of a record mapping, leaving keys and
+// comments of unchanged fields untouched
+func setRecordFields(item *yaml.Node, record *DNSRecord) error {
+	fields := []struct {
+		key   string
+		value interface{}
+		omit  bool
+	}{
+		{"name", record.Name, false},
+		{"type", record.Type, false},
+		{"target", record.Target, false},
+		{"ttl", record.TTL, record.TTL == 0},
+		{"priority", record.Priority, record.Priority == 0},
+	}
+
+	for _, field := range fields {
+		if field.omit {
+			removeMappingKey(item, field.key)
+			continue
+		}
+		if err := setMappingValue(item, field.key, field.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMappingValue sets key to value, preserving the comments attached to an
+// existing value and its quoting style when the content is unchanged
+func setMappingValue(node *yaml.Node, key string, value interface{}) error {
+	var encoded yaml.Node
+	if err := encoded.Encode(value); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+
+	existing := mappingValue(node, key)
+	if existing == nil {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&encoded,
+		)
+		return nil
+	}
+
+	if existing.Kind == yaml.ScalarNode && existing.Value == encoded.Value {
+		return nil
+	}
+
+	encoded.HeadComment = existing.HeadComment
+	encoded.LineComment = existing.LineComment
+	encoded.FootComment = existing.FootComment
+	*existing = encoded
+	return nil
+}
+
+func removeMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// detectIndent returns the smallest indentation used in a YAML document,
+// defaulting to two spaces
+func detectIndent(data []byte) int {
+	indent := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if spaces := len(line) - len(trimmed); spaces > 0 && (indent == 0 || spaces < indent) {
+			indent = spaces
+		}
+	}
+
+	if indent < 2 {
+		return 2
+	}
+	return indent
+}
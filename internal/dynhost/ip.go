@@ -0,0 +1,113 @@
+package dynhost
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultIPv4URL = "https://api.ipify.org"
+	DefaultIPv6URL = "https://api6.ipify.org"
+)
+
+// Detector finds the current public IP address, either by asking an HTTP
+// service that echoes the caller address or by reading a local interface
+type Detector struct {
+	url        string
+	iface      string
+	ipv6       bool
+	httpClient *http.Client
+}
+
+// NewDetector creates a detector. When iface is set the address is read from
+// that interface, otherwise from url (or a default echo service when empty).
+func NewDetector(url, iface string, ipv6 bool) *Detector {
+	if url == "" {
+		url = DefaultIPv4URL
+		if ipv6 {
+			url = DefaultIPv6URL
+		}
+	}
+
+	return &Detector{
+		url:   url,
+		iface: iface,
+		ipv6:  ipv6,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (d *Detector) Detect() (net.IP, error) {
+	if d.iface != "" {
+		return d.detectFromInterface()
+	}
+	return d.detectFromURL()
+}
+
+func (d *Detector) detectFromURL() (net.IP, error) {
+	resp, err := d.httpClient.Get(d.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", d.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to query %s: %s", d.url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", d.url, err)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, fmt.Errorf("%s did not return an IP address", d.url)
+	}
+	if !d.matchesFamily(ip) {
+		return nil, fmt.Errorf("%s returned %s, expected an %s address", d.url, ip, d.family())
+	}
+
+	return ip, nil
+}
+
+func (d *Detector) detectFromInterface() (net.IP, error) {
+	iface, err := net.InterfaceByName(d.iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find interface %s: %w", d.iface, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses of %s: %w", d.iface, err)
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipNet.IP.IsGlobalUnicast() && d.matchesFamily(ipNet.IP) {
+			return ipNet.IP, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no global %s address found on interface %s", d.family(), d.iface)
+}
+
+func (d *Detector) matchesFamily(ip net.IP) bool {
+	return (ip.To4() == nil) == d.ipv6
+}
+
+func (d *Detector) family() string {
+	if d.ipv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
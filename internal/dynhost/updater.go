@@ -0,0 +1,158 @@
+package dynhost
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/ovh"
+)
+
+// Updater keeps the address of a host in sync with the detected public IP.
+// IPv4 addresses are stored in OVH DynHost records; DynHost only supports
+// IPv4, so IPv6 addresses are stored in a regular AAAA record.
+type Updater struct {
+	client    *ovh.Client
+	detector  *Detector
+	zone      string
+	subDomain string
+	ipv6      bool
+	dryRun    bool
+}
+
+func NewUpdater(client *ovh.Client, detector *Detector, zone, subDomain string, ipv6, dryRun bool) *Updater {
+	return &Updater{
+		client:    client,
+		detector:  detector,
+		zone:      zone,
+		subDomain: subDomain,
+		ipv6:      ipv6,
+		dryRun:    dryRun,
+	}
+}
+
+// Update detects the current IP and updates the record when it changed. It
+// reports whether the record was changed.
+func (u *Updater) Update() (bool, error) {
+	ip, err := u.detector.Detect()
+	if err != nil {
+		return false, err
+	}
+
+	var changed bool
+	if u.ipv6 {
+		changed, err = u.updateAAAA(ip)
+	} else {
+		changed, err = u.updateDynHost(ip)
+	}
+	if err != nil || !changed || u.dryRun {
+		return changed, err
+	}
+
+	slog.Info("Refreshing DNS zone", "op", "refresh", "zone", u.zone)
+	return true, u.client.RefreshZone(u.zone)
+}
+
+// Run calls Update every interval until ctx is cancelled. Failed updates are
+// logged and retried on the next tick.
+func (u *Updater) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := u.Update(); err != nil {
+			slog.Error("DynHost update failed", "op", "dynhost", "zone", u.zone, "key", u.subDomain, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (u *Updater) updateDynHost(ip net.IP) (bool, error) {
+	records, err := u.client.GetDynHostRecords(u.zone, u.subDomain)
+	if err != nil {
+		return false, err
+	}
+
+	if len(records) == 0 {
+		slog.Info("Creating DynHost record", "op", "create", "zone", u.zone, "key", u.subDomain+":DYNHOST", "target", ip, "dry_run", u.dryRun)
+		if u.dryRun {
+			return true, nil
+		}
+		_, err := u.client.CreateDynHostRecord(u.zone, &config.OVHDynHostRecord{SubDomain: u.subDomain, IP: ip.String()})
+		if err != nil {
+			return false, fmt.Errorf("failed to create DynHost record: %w", err)
+		}
+		return true, nil
+	}
+
+	if len(records) > 1 {
+		return false, fmt.Errorf("%d DynHost records match %s, delete the extra ones first", len(records), u.subDomain)
+	}
+
+	current := records[0]
+	if net.ParseIP(current.IP).Equal(ip) {
+		slog.Info("DynHost record is up to date", "op", "keep", "zone", u.zone, "key", u.subDomain+":DYNHOST", "id", current.ID, "target", ip)
+		return false, nil
+	}
+
+	slog.Info("Updating DynHost record", "op", "update", "zone", u.zone, "key", u.subDomain+":DYNHOST", "id", current.ID,
+		"target", ip, "previous", current.IP, "dry_run", u.dryRun)
+	if u.dryRun {
+		return true, nil
+	}
+	current.IP = ip.String()
+	if err := u.client.UpdateDynHostRecord(u.zone, current.ID, &current); err != nil {
+		return false, fmt.Errorf("failed to update DynHost record: %w", err)
+	}
+
+	return true, nil
+}
+
+func (u *Updater) updateAAAA(ip net.IP) (bool, error) {
+	records, err := u.client.FindRecords(u.zone, u.subDomain, "AAAA")
+	if err != nil {
+		return false, err
+	}
+
+	record := &config.DNSRecord{Name: u.subDomain, Type: "AAAA", Target: ip.String()}
+	if len(records) == 0 {
+		slog.Info("Creating record", "op", "create", "zone", u.zone, "key", ovh.RecordKey(record), "target", ip, "dry_run", u.dryRun)
+		if u.dryRun {
+			return true, nil
+		}
+		if _, err := u.client.CreateRecord(u.zone, ovh.ConvertDNSRecordToOVHCreate(record)); err != nil {
+			return false, fmt.Errorf("failed to create AAAA record: %w", err)
+		}
+		return true, nil
+	}
+
+	if len(records) > 1 {
+		return false, fmt.Errorf("%d records match %s, delete the extra ones first", len(records), ovh.RecordKey(record))
+	}
+
+	current := records[0]
+	if net.ParseIP(current.Target).Equal(ip) {
+		slog.Info("Record is up to date", "op", "keep", "zone", u.zone, "key", ovh.RecordKey(record), "id", current.ID, "target", ip)
+		return false, nil
+	}
+
+	slog.Info("Updating record", "op", "update", "zone", u.zone, "key", ovh.RecordKey(record), "id", current.ID,
+		"target", ip, "previous", current.Target, "dry_run", u.dryRun)
+	if u.dryRun {
+		return true, nil
+	}
+	record.TTL = current.TTL
+	if err := u.client.UpdateRecord(u.zone, current.ID, ovh.ConvertDNSRecordToOVHUpdate(record)); err != nil {
+		return false, fmt.Errorf("failed to update AAAA record: %w", err)
+	}
+
+	return true, nil
+}
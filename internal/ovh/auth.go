@@ -0,0 +1,183 @@
+package ovh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ovh-dns-manager/internal/config"
+)
+
+// Consumer key statuses reported by the API
+const (
+	CredentialPending   = "pendingValidation"
+	CredentialValidated = "validated"
+	CredentialRefused   = "refused"
+	CredentialExpired   = "expired"
+)
+
+// AccessRules returns the API access this tool needs: every DNS zone of the
+// account, or only the given zones
+func AccessRules(zones []string) []config.OVHAccessRule {
+	rules := []config.OVHAccessRule{
+		{Method: "GET", Path: "/auth/currentCredential"},
+		{Method: "GET", Path: "/me"},
+		{Method: "GET", Path: "/domain/zone"},
+	}
+
+	paths := []string{"/domain/zone/*"}
+	if len(zones) > 0 {
+		paths = nil
+		for _, zone := range zones {
+			rules = append(rules, config.OVHAccessRule{Method: "GET", Path: "/domain/zone/" + zone})
+			paths = append(paths, "/domain/zone/"+zone+"/*")
+		}
+	}
+
+	for _, path := range paths {
+		for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+			rules = append(rules, config.OVHAccessRule{Method: method, Path: path})
+		}
+	}
+
+	return rules
+}
+
+// RequestCredential asks for a new consumer key with the given access rules.
+// The key can only be used once the account owner has opened the returned
+// validation URL and granted access.
+func (c *Client) RequestCredential(rules []config.OVHAccessRule, redirection string) (*config.OVHCredentialToken, error) {
+	body, err := json.Marshal(config.OVHCredentialRequest{AccessRules: rules, Redirection: redirection})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credential request: %w", err)
+	}
+
+	resp, err := c.doUnsignedRequest("POST", "/auth/credential", string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var token config.OVHCredentialToken
+	if err := readJSONResponse(resp, &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// GetCurrentCredential returns the consumer key the client signs requests with
+func (c *Client) GetCurrentCredential() (*config.OVHCurrentCredential, error) {
+	resp, err := c.doRequest("GET", "/auth/currentCredential", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var credential config.OVHCurrentCredential
+	if err := readJSONResponse(resp, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// GetAccount returns the account the consumer key acts for
+func (c *Client) GetAccount() (*config.OVHAccount, error) {
+	resp, err := c.doRequest("GET", "/me", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var account config.OVHAccount
+	if err := readJSONResponse(resp, &account); err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// MissingZoneAccess returns the calls made to manage zone that rules do not
+// allow
+func MissingZoneAccess(rules []config.OVHAccessRule, zone string) []config.OVHAccessRule {
+	required := []config.OVHAccessRule{
+		{Method: "GET", Path: "/domain/zone/" + zone},
+		{Method: "GET", Path: "/domain/zone/" + zone + "/record"},
+		{Method: "POST", Path: "/domain/zone/" + zone + "/record"},
+		{Method: "PUT", Path: "/domain/zone/" + zone + "/record/{id}"},
+		{Method: "DELETE", Path: "/domain/zone/" + zone + "/record/{id}"},
+		{Method: "POST", Path: "/domain/zone/" + zone + "/refresh"},
+	}
+
+	var missing []config.OVHAccessRule
+	for _, call := range required {
+		if !RulesAllow(rules, call.Method, call.Path) {
+			missing = append(missing, call)
+		}
+	}
+	return missing
+}
+
+// RulesAllow reports whether a call is allowed by access rules, where a *
+// in a rule path matches any sequence of characters
+func RulesAllow(rules []config.OVHAccessRule, method, path string) bool {
+	for _, rule := range rules {
+		if strings.EqualFold(rule.Method, method) && matchRulePath(rule.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRulePath(pattern, path string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == path
+	}
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(path, part)
+		if index < 0 {
+			return false
+		}
+		path = path[index+len(part):]
+	}
+
+	return strings.HasSuffix(path, parts[len(parts)-1])
+}
+
+// WaitForValidation polls the consumer key of the client until the account
+// owner validates it, refuses it or ctx is done
+func (c *Client) WaitForValidation(ctx context.Context, interval time.Duration) (*config.OVHCurrentCredential, error) {
+	for {
+		credential, err := c.GetCurrentCredential()
+		switch {
+		case err == nil && credential.Status == CredentialValidated:
+			return credential, nil
+		case err == nil && credential.Status != CredentialPending:
+			return nil, fmt.Errorf("consumer key was not validated: %s", credential.Status)
+		case err != nil && !isForbidden(err):
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("consumer key was not validated in time: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// isForbidden reports whether err is the answer the API gives to requests
+// signed with a consumer key that is not validated yet
+func isForbidden(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden
+}
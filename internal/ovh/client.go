@@ -1,15 +1,19 @@
 package ovh
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha1"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/redact"
 )
 
 const (
@@ -18,12 +22,31 @@ const (
 	EndpointOVHUS = "https://api.ovhcloud.com/1.0"
 )
 
+// APIError is returned for responses with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("API error: %s - %s", e.Status, e.Body)
+	}
+	return fmt.Sprintf("API error: %s", e.Status)
+}
+
 type Client struct {
 	endpoint          string
 	applicationKey    string
 	applicationSecret string
 	consumerKey       string
-	httpClient        *http.Client
+	// oauth2 replaces request signing when authenticating as a service account
+	oauth2     *tokenSource
+	httpClient *http.Client
+	// redactor hides the secrets above in errors, logs and traces
+	redactor *redact.Redactor
+	logger   *slog.Logger
 }
 
 func NewClient(creds *config.OVHCredentials) (*Client, error) {
@@ -46,7 +69,7 @@ func NewClient(creds *config.OVHCredentials) (*Client, error) {
 		timeout = 30 * time.Second
 	}
 
-	return &Client{
+	client := &Client{
 		endpoint:          endpoint,
 		applicationKey:    creds.ApplicationKey,
 		applicationSecret: creds.ApplicationSecret,
@@ -54,7 +77,50 @@ func NewClient(creds *config.OVHCredentials) (*Client, error) {
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
-	}, nil
+		redactor: redact.New(creds.ApplicationSecret, creds.ConsumerKey, creds.ClientSecret),
+		logger:   slog.Default(),
+	}
+
+	if creds.UsesOAuth2() {
+		tokenURL := creds.TokenURL
+		if tokenURL == "" {
+			tokenURL = defaultTokenURL(creds.Endpoint)
+		}
+		if tokenURL == "" {
+			return nil, fmt.Errorf("token_url is required for OAuth2 with endpoint %s", creds.Endpoint)
+		}
+
+		client.oauth2 = &tokenSource{
+			url:          tokenURL,
+			clientID:     creds.ClientID,
+			clientSecret: creds.ClientSecret,
+			httpClient:   client.httpClient,
+			redactor:     client.redactor,
+		}
+	}
+
+	return client, nil
+}
+
+// SetLogger sets the logger API calls are logged to, at debug level
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+// SetTracer records the HTTP exchanges of the client, including OAuth2 token
+// requests, with tracer
+func (c *Client) SetTracer(tracer *Tracer) {
+	next := c.httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.httpClient.Transport = &tracingTransport{tracer: tracer, next: next, redactor: c.redactor}
+}
+
+// UsesOAuth2 reports whether the client authenticates as an OAuth2 service
+// account, which has IAM policies instead of consumer key access rules
+func (c *Client) UsesOAuth2() bool {
+	return c.oauth2 != nil
 }
 
 // generateSignature creates the OVH API signature using SHA1
@@ -79,10 +145,20 @@ func (c *Client) prepareRequest(method, path, body string) (*http.Request, error
 		return nil, err
 	}
 
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.oauth2 != nil {
+		token, err := c.oauth2.Token()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	}
+
 	timestamp := time.Now().Unix()
 	signature := c.generateSignature(method, url, body, timestamp)
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Ovh-Application", c.applicationKey)
 	req.Header.Set("X-Ovh-Consumer", c.consumerKey)
 	req.Header.Set("X-Ovh-Signature", signature)
@@ -97,19 +173,81 @@ func (c *Client) doRequest(method, path, body string) (*http.Response, error) {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
 
+	return c.send(req, body)
+}
+
+// doUnsignedRequest sends a request identified by the application key only,
+// for the calls made before a consumer key exists
+func (c *Client) doUnsignedRequest(method, path, body string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.endpoint+path, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Ovh-Application", c.applicationKey)
+
+	return c.send(req, body)
+}
+
+func (c *Client) send(req *http.Request, body string) (*http.Response, error) {
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		err = fmt.Errorf("request failed: %w", err)
+		c.logCall(req, body, nil, err, time.Since(start))
+		return nil, err
 	}
+	duration := time.Since(start)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		// Try to read the response body for more detailed error information
 		defer resp.Body.Close()
-		if respBody, readErr := io.ReadAll(resp.Body); readErr == nil && len(respBody) > 0 {
-			return resp, fmt.Errorf("API error: %s - %s", resp.Status, string(respBody))
+		if resp.StatusCode == http.StatusUnauthorized && c.oauth2 != nil {
+			c.oauth2.invalidate()
+		}
+		apiErr := &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if respBody, readErr := io.ReadAll(resp.Body); readErr == nil {
+			apiErr.Body = c.redactor.String(string(respBody))
 		}
-		return resp, fmt.Errorf("API error: %s", resp.Status)
+		c.logCall(req, body, resp, apiErr, duration)
+		return resp, apiErr
 	}
 
+	c.logCall(req, body, resp, nil, duration)
 	return resp, nil
-}
\ No newline at end of file
+}
+
+// logCall logs an API call at debug level with its redacted bodies. The
+// response body is read and replaced so that the caller can still read it.
+func (c *Client) logCall(req *http.Request, body string, resp *http.Response, err error, duration time.Duration) {
+	if !c.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	attrs := []any{
+		"method", req.Method,
+		"path", strings.TrimPrefix(req.URL.String(), c.endpoint),
+		"duration", duration.Round(time.Millisecond),
+	}
+	if body != "" {
+		attrs = append(attrs, "request", c.redactor.String(body))
+	}
+	if resp != nil {
+		attrs = append(attrs, "status", resp.StatusCode)
+	}
+
+	switch {
+	case err != nil:
+		attrs = append(attrs, "error", err.Error())
+	case resp != nil:
+		data, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		if readErr == nil {
+			attrs = append(attrs, "response", c.redactor.String(string(data)))
+		}
+	}
+
+	c.logger.Debug("API call", attrs...)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"ovh-dns-manager/internal/config"
 )
@@ -25,6 +26,36 @@ func readJSONResponse(resp *http.Response, v interface{}) error {
 	return nil
 }
 
+// ListZones returns the names of the DNS zones of the account
+func (c *Client) ListZones() ([]string, error) {
+	resp, err := c.doRequest("GET", "/domain/zone", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var zones []string
+	if err := readJSONResponse(resp, &zones); err != nil {
+		return nil, err
+	}
+
+	return zones, nil
+}
+
+func (c *Client) GetZone(zoneName string) (*config.OVHZone, error) {
+	path := fmt.Sprintf("/domain/zone/%s", zoneName)
+	resp, err := c.doRequest("GET", path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var zone config.OVHZone
+	if err := readJSONResponse(resp, &zone); err != nil {
+		return nil, err
+	}
+
+	return &zone, nil
+}
+
 func (c *Client) GetZoneRecords(zoneName string) ([]config.OVHRecord, error) {
 	path := fmt.Sprintf("/domain/zone/%s/record", zoneName)
 	resp, err := c.doRequest("GET", path, "")
@@ -49,6 +80,38 @@ func (c *Client) GetZoneRecords(zoneName string) ([]config.OVHRecord, error) {
 	return records, nil
 }
 
+// FindRecords returns the records of a zone matching a subdomain and,
+// when fieldType is not empty, a record type
+func (c *Client) FindRecords(zoneName, subDomain, fieldType string) ([]config.OVHRecord, error) {
+	query := url.Values{}
+	query.Set("subDomain", subDomain)
+	if fieldType != "" {
+		query.Set("fieldType", fieldType)
+	}
+
+	path := fmt.Sprintf("/domain/zone/%s/record?%s", zoneName, query.Encode())
+	resp, err := c.doRequest("GET", path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var recordIDs []int64
+	if err := readJSONResponse(resp, &recordIDs); err != nil {
+		return nil, err
+	}
+
+	var records []config.OVHRecord
+	for _, id := range recordIDs {
+		record, err := c.GetRecord(zoneName, id)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+
+	return records, nil
+}
+
 func (c *Client) GetRecord(zoneName string, recordID int64) (*config.OVHRecord, error) {
 	path := fmt.Sprintf("/domain/zone/%s/record/%d", zoneName, recordID)
 	resp, err := c.doRequest("GET", path, "")
@@ -178,19 +241,36 @@ func ConvertDNSRecordToOVHUpdate(dnsRecord *config.DNSRecord) *config.OVHRecordU
 	}
 }
 
+// RecordsEqual compares two records, treating an unset TTL as the default TTL
+// applied on creation
 func RecordsEqual(a, b *config.DNSRecord) bool {
-	return a.Name == b.Name &&
-		a.Type == b.Type &&
+	return RecordKey(a) == RecordKey(b) &&
 		a.Target == b.Target &&
-		a.TTL == b.TTL &&
+		effectiveTTL(a.TTL) == effectiveTTL(b.TTL) &&
 		a.Priority == b.Priority
 }
 
+func effectiveTTL(ttl int) int {
+	if ttl == 0 {
+		return config.DefaultTTL
+	}
+	return ttl
+}
+
+// RecordKey identifies a record by name and type, spelling the apex as OVH
+// does whether the zone file writes it "@" or leaves it empty
 func RecordKey(record *config.DNSRecord) string {
-	return record.Name + ":" + record.Type
+	return recordKey(record.Name, record.Type)
 }
 
 func OVHRecordKey(record *config.OVHRecord) string {
-	return record.SubDomain + ":" + record.FieldType
+	return recordKey(record.SubDomain, record.FieldType)
+}
+
+func recordKey(name, recordType string) string {
+	if config.IsApex(name) {
+		name = ""
+	}
+	return name + ":" + recordType
 }
 
@@ -0,0 +1,64 @@
+package ovh
+
+import (
+	"fmt"
+
+	"ovh-dns-manager/internal/config"
+)
+
+// DNSSEC states reported by OVH
+const (
+	DNSSECEnabled           = "enabled"
+	DNSSECDisabled          = "disabled"
+	DNSSECEnableInProgress  = "enableInProgress"
+	DNSSECDisableInProgress = "disableInProgress"
+)
+
+// GetDNSSEC returns the DNSSEC state of a zone
+func (c *Client) GetDNSSEC(zoneName string) (*config.OVHDNSSEC, error) {
+	path := fmt.Sprintf("/domain/zone/%s/dnssec", zoneName)
+	resp, err := c.doRequest("GET", path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var dnssec config.OVHDNSSEC
+	if err := readJSONResponse(resp, &dnssec); err != nil {
+		return nil, err
+	}
+
+	return &dnssec, nil
+}
+
+// EnableDNSSEC starts signing a zone. OVH publishes the DS records at the
+// registry when the domain is registered with OVH.
+func (c *Client) EnableDNSSEC(zoneName string) error {
+	path := fmt.Sprintf("/domain/zone/%s/dnssec", zoneName)
+
+	resp, err := c.doRequest("POST", path, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// DisableDNSSEC stops signing a zone
+func (c *Client) DisableDNSSEC(zoneName string) error {
+	path := fmt.Sprintf("/domain/zone/%s/dnssec", zoneName)
+
+	resp, err := c.doRequest("DELETE", path, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// DNSSECActive reports whether a DNSSEC state is enabled or on its way to
+// being enabled
+func DNSSECActive(dnssec *config.OVHDNSSEC) bool {
+	return dnssec.Status == DNSSECEnabled || dnssec.Status == DNSSECEnableInProgress
+}
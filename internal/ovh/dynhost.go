@@ -0,0 +1,113 @@
+package ovh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"ovh-dns-manager/internal/config"
+)
+
+// GetDynHostRecords returns the DynHost records of a zone for a subdomain
+func (c *Client) GetDynHostRecords(zoneName, subDomain string) ([]config.OVHDynHostRecord, error) {
+	query := url.Values{}
+	query.Set("subDomain", subDomain)
+
+	path := fmt.Sprintf("/domain/zone/%s/dynHost/record?%s", zoneName, query.Encode())
+	resp, err := c.doRequest("GET", path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var recordIDs []int64
+	if err := readJSONResponse(resp, &recordIDs); err != nil {
+		return nil, err
+	}
+
+	var records []config.OVHDynHostRecord
+	for _, id := range recordIDs {
+		path := fmt.Sprintf("/domain/zone/%s/dynHost/record/%d", zoneName, id)
+		resp, err := c.doRequest("GET", path, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var record config.OVHDynHostRecord
+		if err := readJSONResponse(resp, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (c *Client) CreateDynHostRecord(zoneName string, record *config.OVHDynHostRecord) (*config.OVHDynHostRecord, error) {
+	path := fmt.Sprintf("/domain/zone/%s/dynHost/record", zoneName)
+
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRequest("POST", path, string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var createdRecord config.OVHDynHostRecord
+	if err := readJSONResponse(resp, &createdRecord); err != nil {
+		return nil, err
+	}
+
+	return &createdRecord, nil
+}
+
+func (c *Client) UpdateDynHostRecord(zoneName string, recordID int64, record *config.OVHDynHostRecord) error {
+	path := fmt.Sprintf("/domain/zone/%s/dynHost/record/%d", zoneName, recordID)
+
+	body, err := json.Marshal(&config.OVHDynHostRecord{SubDomain: record.SubDomain, IP: record.IP})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doRequest("PUT", path, string(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// GetDynHostLogins returns the DynHost logins that routers and clients use
+// to update DynHost records through the DynDNS protocol
+func (c *Client) GetDynHostLogins(zoneName string) ([]config.OVHDynHostLogin, error) {
+	path := fmt.Sprintf("/domain/zone/%s/dynHost/login", zoneName)
+	resp, err := c.doRequest("GET", path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := readJSONResponse(resp, &names); err != nil {
+		return nil, err
+	}
+
+	var logins []config.OVHDynHostLogin
+	for _, name := range names {
+		path := fmt.Sprintf("/domain/zone/%s/dynHost/login/%s", zoneName, url.PathEscape(name))
+		resp, err := c.doRequest("GET", path, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var login config.OVHDynHostLogin
+		if err := readJSONResponse(resp, &login); err != nil {
+			return nil, err
+		}
+		logins = append(logins, login)
+	}
+
+	return logins, nil
+}
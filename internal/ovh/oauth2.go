@@ -0,0 +1,117 @@
+package ovh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ovh-dns-manager/internal/redact"
+)
+
+// OAuth2 token URLs of the OVHcloud regions
+const (
+	TokenURLOVHEU = "https://www.ovh.com/auth/oauth2/token"
+	TokenURLOVHCA = "https://ca.ovh.com/auth/oauth2/token"
+	TokenURLOVHUS = "https://us.ovhcloud.com/auth/oauth2/token"
+)
+
+// tokenExpiryMargin renews tokens this long before they expire, so that a
+// token does not expire while a request is in flight. Short-lived tokens use
+// a quarter of their lifetime instead, so they are still reused.
+const tokenExpiryMargin = time.Minute
+
+// tokenSource fetches bearer tokens with the OAuth2 client credentials grant
+// and caches them until they are about to expire
+type tokenSource struct {
+	url          string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	redactor     *redact.Redactor
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// defaultTokenURL returns the token URL of a named endpoint
+func defaultTokenURL(endpoint string) string {
+	switch endpoint {
+	case "ovh-eu", "":
+		return TokenURLOVHEU
+	case "ovh-ca":
+		return TokenURLOVHCA
+	case "ovh-us":
+		return TokenURLOVHUS
+	default:
+		return ""
+	}
+}
+
+// Token returns a valid bearer token, requesting a new one when the cached
+// token is missing or about to expire
+func (t *tokenSource) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Before(t.expiry) {
+		return t.token, nil
+	}
+
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"all"},
+	}
+	req, err := http.NewRequest("POST", t.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(t.clientID), url.QueryEscape(t.clientSecret))
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("token request failed: %s - %s", resp.Status, t.redactor.String(string(body)))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token response has no access token")
+	}
+
+	t.token = token.AccessToken
+	t.redactor.Add(token.AccessToken)
+	lifetime := time.Duration(token.ExpiresIn) * time.Second
+	t.expiry = time.Now().Add(lifetime - min(tokenExpiryMargin, lifetime/4))
+	return t.token, nil
+}
+
+// invalidate drops the cached token, after the API rejected it
+func (t *tokenSource) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = ""
+}
@@ -0,0 +1,69 @@
+package ovh
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"ovh-dns-manager/internal/redact"
+)
+
+// Tracer records the HTTP exchanges of clients, with credentials redacted,
+// so that API problems can be reproduced precisely. It can be shared by
+// several clients.
+type Tracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTracer returns a Tracer writing to w
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+func (t *Tracer) write(start time.Time, duration time.Duration, request, response []byte, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintf(t.w, "=== %s (%s)\n%s\n", start.Format(time.RFC3339Nano), duration.Round(time.Millisecond), request)
+	if err != nil {
+		fmt.Fprintf(t.w, "--- error\n%v\n\n", err)
+		return
+	}
+	fmt.Fprintf(t.w, "--- response\n%s\n\n", response)
+}
+
+// tracingTransport dumps requests and responses to a Tracer
+type tracingTransport struct {
+	tracer   *Tracer
+	next     http.RoundTripper
+	redactor *redact.Redactor
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, dumpErr := httputil.DumpRequestOut(req, true)
+	if dumpErr != nil {
+		request = []byte(fmt.Sprintf("%s %s (dump failed: %v)", req.Method, req.URL, dumpErr))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	duration := time.Since(start)
+
+	var response []byte
+	if err == nil {
+		if response, dumpErr = httputil.DumpResponse(resp, true); dumpErr != nil {
+			response = []byte(fmt.Sprintf("%s (dump failed: %v)", resp.Status, dumpErr))
+		}
+	}
+
+	t.tracer.write(start, duration,
+		[]byte(t.redactor.String(string(request))),
+		[]byte(t.redactor.String(string(response))),
+		err)
+
+	return resp, err
+}
@@ -0,0 +1,79 @@
+// Package redact removes credentials from text that may be shown to users,
+// such as error messages and debug logs.
+package redact
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces redacted values
+const Placeholder = "[REDACTED]"
+
+// minSecretLength avoids redacting short values that would mangle unrelated
+// text, such as an empty or single character secret
+const minSecretLength = 4
+
+// sensitiveKeys are the names of credential fields in JSON bodies and form
+// values
+const sensitiveKeys = `application_?secret|consumer_?key|client_?secret|access_?token|refresh_?token|password`
+
+// sensitiveFields match the values of credential fields, and nothing else, so
+// that messages merely naming a field, such as "failed to resolve
+// consumer_key: ...", stay readable. Each keeps the field name in its first
+// group.
+var sensitiveFields = []*regexp.Regexp{
+	// JSON values, such as "consumerKey":"..."
+	regexp.MustCompile(`(?i)("(?:` + sensitiveKeys + `)"\s*:\s*")((?:[^"\\]|\\.)+)`),
+	// Form values, such as client_secret=...
+	regexp.MustCompile(`(?i)((?:^|[?&\s])(?:` + sensitiveKeys + `)=)([^&\s]+)`),
+	// Header lines, such as Authorization: Bearer ...
+	regexp.MustCompile(`(?im)(^(?:authorization|x-ovh-signature|x-ovh-consumer)\s*:\s*)([^\r\n]+)`),
+}
+
+// Redactor replaces known secret values and the values of credential fields
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// New returns a Redactor for the given secret values; empty values are
+// ignored
+func New(secrets ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(secrets...)
+	return r
+}
+
+// Add registers more secret values to redact
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+}
+
+// String returns text with secrets replaced by Placeholder
+func (r *Redactor) String(text string) string {
+	if r != nil {
+		r.mu.RLock()
+		for _, secret := range r.secrets {
+			text = strings.ReplaceAll(text, secret, Placeholder)
+		}
+		r.mu.RUnlock()
+	}
+	for _, field := range sensitiveFields {
+		text = field.ReplaceAllString(text, "${1}"+Placeholder)
+	}
+	return text
+}
+
+// String redacts the values of credential fields in text, for callers that
+// do not know the secret values
+func String(text string) string {
+	return (*Redactor)(nil).String(text)
+}
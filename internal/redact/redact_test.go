@@ -0,0 +1,61 @@
+package redact
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			"wrapped secret command error",
+			"failed to resolve application_secret: run pass: exit status 1",
+			"failed to resolve application_secret: run pass: exit status 1",
+		},
+		{
+			"wrapped environment error",
+			"failed to resolve consumer_key: FOO is not set",
+			"failed to resolve consumer_key: FOO is not set",
+		},
+		{
+			"JSON value",
+			`{"validationUrl":"https://example.com","consumerKey":"ck-123456","state":"pendingValidation"}`,
+			`{"validationUrl":"https://example.com","consumerKey":"[REDACTED]","state":"pendingValidation"}`,
+		},
+		{
+			"JSON value with escaped quote",
+			`{"access_token": "abc\"def", "expires_in": 3600}`,
+			`{"access_token": "[REDACTED]", "expires_in": 3600}`,
+		},
+		{
+			"form values",
+			"grant_type=client_credentials&client_id=id&client_secret=s3cr3t&scope=all",
+			"grant_type=client_credentials&client_id=id&client_secret=[REDACTED]&scope=all",
+		},
+		{
+			"header lines",
+			"GET /1.0/me HTTP/1.1\r\nAuthorization: Bearer tok-123456\r\nX-Ovh-Consumer: ck-123456\r\nX-Ovh-Signature: $1$abcdef\r\nX-Ovh-Timestamp: 1700000000\r\n",
+			"GET /1.0/me HTTP/1.1\r\nAuthorization: [REDACTED]\r\nX-Ovh-Consumer: [REDACTED]\r\nX-Ovh-Signature: [REDACTED]\r\nX-Ovh-Timestamp: 1700000000\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.text); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorSecrets(t *testing.T) {
+	r := New("app-secret-value", "ck-123456", "abc", "")
+	r.Add("late-token")
+
+	text := "failed to resolve application_secret: app-secret-value rejected, ck-123456 and late-token too, abc kept"
+	want := "failed to resolve application_secret: [REDACTED] rejected, [REDACTED] and [REDACTED] too, abc kept"
+	if got := r.String(text); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
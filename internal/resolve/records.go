@@ -0,0 +1,230 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"ovh-dns-manager/internal/config"
+)
+
+// Expectation describes the state a record should reach on the servers:
+// its value answered, or with Absent, no longer answered
+type Expectation struct {
+	Record config.DNSRecord
+	Absent bool
+}
+
+// RecordStatus is the outcome of waiting for one expectation
+type RecordStatus struct {
+	Expectation
+	Ready    bool
+	Elapsed  time.Duration
+	Observed []string
+	Err      error
+}
+
+// WaitForRecords polls the servers until every expectation holds on all of
+// them or ctx is done, and returns the status of each expectation
+func (r *Resolver) WaitForRecords(ctx context.Context, zone string, expectations []Expectation, interval time.Duration) []RecordStatus {
+	start := time.Now()
+	statuses := make([]RecordStatus, len(expectations))
+	for i, expectation := range expectations {
+		statuses[i].Expectation = expectation
+	}
+
+	for {
+		pending := 0
+		for i := range statuses {
+			status := &statuses[i]
+			if status.Ready {
+				continue
+			}
+
+			status.Ready, status.Observed, status.Err = r.check(zone, &status.Expectation)
+			if status.Ready {
+				status.Elapsed = time.Since(start)
+				slog.Info("Record is live", "op", "wait", "zone", zone, "key", status.Record.Name+":"+status.Record.Type,
+					"fqdn", RecordFQDN(zone, status.Record.Name), "absent", status.Absent, "elapsed", status.Elapsed.Round(time.Second))
+			} else {
+				pending++
+			}
+		}
+
+		if pending == 0 {
+			return statuses
+		}
+
+		select {
+		case <-ctx.Done():
+			for i := range statuses {
+				if !statuses[i].Ready {
+					statuses[i].Elapsed = time.Since(start)
+				}
+			}
+			return statuses
+		case <-time.After(interval):
+		}
+	}
+}
+
+// check reports whether an expectation holds on every server, along with
+// the values observed on the first server where it does not
+func (r *Resolver) check(zone string, expectation *Expectation) (bool, []string, error) {
+	record := &expectation.Record
+	name := RecordFQDN(zone, record.Name)
+	expected := ExpectedValue(zone, record)
+
+	for _, server := range r.servers {
+		answers, err := r.Lookup(server, name, QueryType(record.Type))
+		if err != nil {
+			return false, nil, err
+		}
+
+		observed := AnswerValues(answers)
+		found := false
+		for _, value := range observed {
+			if value == expected {
+				found = true
+				break
+			}
+		}
+
+		if found == expectation.Absent {
+			return false, observed, nil
+		}
+	}
+
+	return true, nil, nil
+}
+
+// RecordFQDN returns the fully qualified name of a record of zone
+func RecordFQDN(zone, name string) string {
+	if config.IsApex(name) {
+		return zone
+	}
+	return name + "." + zone
+}
+
+// QueryType returns the DNS type to query for an OVH record type. OVH
+// publishes SPF records as TXT.
+func QueryType(recordType string) uint16 {
+	if recordType == "SPF" {
+		return dns.TypeTXT
+	}
+	return dns.StringToType[recordType]
+}
+
+// ExpectedValue renders the target of a record the way AnswerValues renders
+// the matching answer, so both can be compared as strings
+func ExpectedValue(zone string, record *config.DNSRecord) string {
+	target := strings.TrimSpace(record.Target)
+	fields := strings.Fields(target)
+
+	switch record.Type {
+	case "A", "AAAA":
+		if ip := net.ParseIP(target); ip != nil {
+			return ip.String()
+		}
+		return target
+	case "CNAME", "NS", "PTR":
+		return absoluteName(zone, target)
+	case "MX":
+		// OVH accepts the priority either in its own field or in the target
+		if len(fields) == 2 {
+			return fields[0] + " " + absoluteName(zone, fields[1])
+		}
+		return fmt.Sprintf("%d %s", record.Priority, absoluteName(zone, target))
+	case "SRV":
+		if len(fields) == 3 {
+			fields = append([]string{fmt.Sprint(record.Priority)}, fields...)
+		}
+		if len(fields) == 4 {
+			fields[3] = absoluteName(zone, fields[3])
+		}
+		return strings.Join(fields, " ")
+	case "TXT", "SPF":
+		return joinQuoted(target)
+	case "CAA":
+		if len(fields) >= 3 {
+			return fields[0] + " " + strings.ToLower(fields[1]) + " " + strings.Trim(strings.Join(fields[2:], " "), `"`)
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// AnswerValues renders answers in the format of ExpectedValue
+func AnswerValues(answers []dns.RR) []string {
+	values := make([]string, 0, len(answers))
+	for _, rr := range answers {
+		switch v := rr.(type) {
+		case *dns.A:
+			values = append(values, v.A.String())
+		case *dns.AAAA:
+			values = append(values, v.AAAA.String())
+		case *dns.CNAME:
+			values = append(values, strings.ToLower(v.Target))
+		case *dns.NS:
+			values = append(values, strings.ToLower(v.Ns))
+		case *dns.PTR:
+			values = append(values, strings.ToLower(v.Ptr))
+		case *dns.MX:
+			values = append(values, fmt.Sprintf("%d %s", v.Preference, strings.ToLower(v.Mx)))
+		case *dns.SRV:
+			values = append(values, fmt.Sprintf("%d %d %d %s", v.Priority, v.Weight, v.Port, strings.ToLower(v.Target)))
+		case *dns.TXT:
+			values = append(values, strings.Join(v.Txt, ""))
+		case *dns.SPF:
+			values = append(values, strings.Join(v.Txt, ""))
+		case *dns.CAA:
+			values = append(values, fmt.Sprintf("%d %s %s", v.Flag, strings.ToLower(v.Tag), v.Value))
+		default:
+			values = append(values, strings.Join(strings.Fields(strings.TrimPrefix(rr.String(), rr.Header().String())), " "))
+		}
+	}
+	return values
+}
+
+// absoluteName qualifies a host name relative to zone, as OVH does for
+// targets without a trailing dot
+func absoluteName(zone, host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case strings.HasSuffix(host, "."):
+		return host
+	case config.IsApex(host):
+		return strings.ToLower(zone) + "."
+	default:
+		return host + "." + strings.ToLower(zone) + "."
+	}
+}
+
+// joinQuoted turns a TXT target made of quoted strings into the text clients
+// see; unquoted targets are returned unchanged
+func joinQuoted(target string) string {
+	if !strings.HasPrefix(target, `"`) {
+		return target
+	}
+
+	var text strings.Builder
+	inQuotes, escaped := false, false
+	for _, c := range target {
+		switch {
+		case escaped:
+			text.WriteRune(c)
+			escaped = false
+		case c == '\\' && inQuotes:
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+			text.WriteRune(c)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,223 @@
+package resolve
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"ovh-dns-manager/internal/config"
+)
+
+// testServer is an in-process authoritative DNS server whose records can be
+// changed while a test runs
+type testServer struct {
+	addr string
+
+	mu      sync.Mutex
+	records []dns.RR
+}
+
+func newTestServer(t *testing.T, records ...string) *testServer {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ts := &testServer{addr: conn.LocalAddr().String()}
+	ts.set(parseRRs(t, records...))
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        conn,
+		Handler:           dns.HandlerFunc(ts.serve),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+
+	return ts
+}
+
+// set replaces the records served
+func (ts *testServer) set(records []dns.RR) {
+	ts.mu.Lock()
+	ts.records = records
+	ts.mu.Unlock()
+}
+
+func (ts *testServer) serve(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+
+	question := req.Question[0]
+	ts.mu.Lock()
+	for _, rr := range ts.records {
+		if strings.EqualFold(rr.Header().Name, question.Name) && rr.Header().Rrtype == question.Qtype {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	ts.mu.Unlock()
+
+	w.WriteMsg(resp)
+}
+
+// parseRRs parses records given in zone file syntax
+func parseRRs(t *testing.T, records ...string) []dns.RR {
+	t.Helper()
+
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatalf("parse %q: %v", record, err)
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+func TestExpectedValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		record config.DNSRecord
+		want   string
+	}{
+		{"A", config.DNSRecord{Type: "A", Target: " 192.0.2.1 "}, "192.0.2.1"},
+		{"AAAA compressed", config.DNSRecord{Type: "AAAA", Target: "2001:DB8:0:0::1"}, "2001:db8::1"},
+		{"CNAME relative", config.DNSRecord{Type: "CNAME", Target: "Web"}, "web.example.com."},
+		{"CNAME absolute", config.DNSRecord{Type: "CNAME", Target: "Host.Example.NET."}, "host.example.net."},
+		{"CNAME apex", config.DNSRecord{Type: "CNAME", Target: "@"}, "example.com."},
+		{"MX priority field", config.DNSRecord{Type: "MX", Target: "mail", Priority: 10}, "10 mail.example.com."},
+		{"MX priority in target", config.DNSRecord{Type: "MX", Target: "5 MX1.example.org."}, "5 mx1.example.org."},
+		{"SRV priority field", config.DNSRecord{Type: "SRV", Target: "5 5060 sip", Priority: 10}, "10 5 5060 sip.example.com."},
+		{"SRV priority in target", config.DNSRecord{Type: "SRV", Target: "0 1 443 Svc.Example.org."}, "0 1 443 svc.example.org."},
+		{"TXT unquoted", config.DNSRecord{Type: "TXT", Target: "v=DMARC1; p=none;"}, "v=DMARC1; p=none;"},
+		{"TXT split strings", config.DNSRecord{Type: "TXT", Target: `"v=spf1 include:_spf.example.com " "-all"`}, "v=spf1 include:_spf.example.com -all"},
+		{"SPF", config.DNSRecord{Type: "SPF", Target: `"v=spf1 -all"`}, "v=spf1 -all"},
+		{"CAA", config.DNSRecord{Type: "CAA", Target: `0 Issue "letsencrypt.org"`}, "0 issue letsencrypt.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpectedValue("example.com", &tt.record); got != tt.want {
+				t.Errorf("ExpectedValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerValues(t *testing.T) {
+	tests := []struct {
+		record string
+		want   string
+	}{
+		{"a.example.com. 60 IN A 192.0.2.1", "192.0.2.1"},
+		{"a.example.com. 60 IN AAAA 2001:db8::1", "2001:db8::1"},
+		{"a.example.com. 60 IN CNAME Web.Example.com.", "web.example.com."},
+		{"a.example.com. 60 IN MX 10 Mail.Example.com.", "10 mail.example.com."},
+		{"a.example.com. 60 IN SRV 10 5 5060 SIP.example.com.", "10 5 5060 sip.example.com."},
+		{`a.example.com. 60 IN TXT "v=spf1 include:_spf.example.com " "-all"`, "v=spf1 include:_spf.example.com -all"},
+		{`a.example.com. 60 IN CAA 0 Issue "letsencrypt.org"`, "0 issue letsencrypt.org"},
+	}
+
+	for _, tt := range tests {
+		got := AnswerValues(parseRRs(t, tt.record))
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("AnswerValues(%q) = %q, want %q", tt.record, got, tt.want)
+		}
+	}
+}
+
+func TestJoinQuoted(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"plain text", "plain text"},
+		{`"one string"`, "one string"},
+		{`"first " "second"`, "first second"},
+		{`"with \"quotes\" inside"`, `with "quotes" inside`},
+		{`"back\\slash"`, `back\slash`},
+	}
+
+	for _, tt := range tests {
+		if got := joinQuoted(tt.target); got != tt.want {
+			t.Errorf("joinQuoted(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestWaitForRecords(t *testing.T) {
+	server := newTestServer(t,
+		`old.example.com. 60 IN TXT "stale"`,
+		"mail.example.com. 60 IN MX 20 backup.example.com.",
+	)
+
+	expectations := []Expectation{
+		{Record: config.DNSRecord{Name: "spf", Type: "TXT", Target: `"v=spf1 " "-all"`}},
+		{Record: config.DNSRecord{Name: "mail", Type: "MX", Target: "mx1", Priority: 10}},
+		{Record: config.DNSRecord{Name: "old", Type: "TXT", Target: "stale"}, Absent: true},
+	}
+
+	// Publish the changes after the first polls
+	published := parseRRs(t,
+		`spf.example.com. 60 IN TXT "v=spf1 -all"`,
+		"mail.example.com. 60 IN MX 10 MX1.example.com.",
+	)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		server.set(published)
+	}()
+
+	resolver := NewResolver([]string{server.addr}, time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	statuses := resolver.WaitForRecords(ctx, "example.com", expectations, 10*time.Millisecond)
+	for _, status := range statuses {
+		if !status.Ready {
+			t.Errorf("%s %s not ready: observed %q, err %v", status.Record.Name, status.Record.Type, status.Observed, status.Err)
+		}
+	}
+}
+
+func TestWaitForRecordsTimeout(t *testing.T) {
+	server := newTestServer(t,
+		"www.example.com. 60 IN A 192.0.2.1",
+		`old.example.com. 60 IN TXT "stale"`,
+	)
+
+	expectations := []Expectation{
+		{Record: config.DNSRecord{Name: "www", Type: "A", Target: "192.0.2.2"}},
+		{Record: config.DNSRecord{Name: "old", Type: "TXT", Target: `"stale"`}, Absent: true},
+	}
+
+	resolver := NewResolver([]string{server.addr}, time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	statuses := resolver.WaitForRecords(ctx, "example.com", expectations, 10*time.Millisecond)
+
+	www := statuses[0]
+	if www.Ready {
+		t.Fatalf("www A ready, want pending")
+	}
+	if len(www.Observed) != 1 || www.Observed[0] != "192.0.2.1" {
+		t.Errorf("www A observed %q, want [192.0.2.1]", www.Observed)
+	}
+	if www.Elapsed == 0 {
+		t.Errorf("www A elapsed not set")
+	}
+
+	if statuses[1].Ready {
+		t.Errorf("old TXT ready while still answered")
+	}
+}
@@ -0,0 +1,151 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"ovh-dns-manager/internal/ovh"
+)
+
+const DefaultTimeout = 5 * time.Second
+
+// Resolver sends queries straight to a fixed set of DNS servers, usually the
+// authoritative name servers of a zone, bypassing any recursive cache
+type Resolver struct {
+	client    *dns.Client
+	servers   []string
+	recursive bool
+}
+
+// NewResolver creates a resolver for servers given as host or host:port
+func NewResolver(servers []string, timeout time.Duration) *Resolver {
+	addresses := make([]string, 0, len(servers))
+	for _, server := range servers {
+		addresses = append(addresses, ServerAddress(server))
+	}
+
+	return &Resolver{
+		client:  &dns.Client{Timeout: timeout},
+		servers: addresses,
+	}
+}
+
+// NewRecursiveResolver creates a resolver that asks servers for recursion,
+// to see records the way clients of a public resolver do
+func NewRecursiveResolver(servers []string, timeout time.Duration) *Resolver {
+	resolver := NewResolver(servers, timeout)
+	resolver.recursive = true
+	return resolver
+}
+
+// NewAuthoritativeResolver creates a resolver for the name servers OVH lists
+// for a zone
+func NewAuthoritativeResolver(client *ovh.Client, zoneName string, timeout time.Duration) (*Resolver, error) {
+	zone, err := client.GetZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(zone.NameServers) == 0 {
+		return nil, fmt.Errorf("no name servers found for zone %s", zoneName)
+	}
+
+	return NewResolver(zone.NameServers, timeout), nil
+}
+
+// ServerAddress appends the default DNS port to a server without one
+func ServerAddress(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.TrimSuffix(server, "."), "53")
+}
+
+func (r *Resolver) Servers() []string {
+	return r.servers
+}
+
+// Recursive reports whether answers may come from a cache
+func (r *Resolver) Recursive() bool {
+	return r.recursive
+}
+
+// Lookup asks a single server for the records of a name and type. A name
+// that does not exist yields no records and no error.
+func (r *Resolver) Lookup(server, name string, qtype uint16) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = r.recursive
+
+	resp, _, err := r.client.Exchange(msg, server)
+	if err != nil {
+		return nil, fmt.Errorf("query %s %s to %s failed: %w", name, dns.TypeToString[qtype], server, err)
+	}
+
+	// Large TXT sets may not fit in UDP, retry over TCP
+	if resp.Truncated {
+		tcpClient := *r.client
+		tcpClient.Net = "tcp"
+		resp, _, err = tcpClient.Exchange(msg, server)
+		if err != nil {
+			return nil, fmt.Errorf("query %s %s to %s failed: %w", name, dns.TypeToString[qtype], server, err)
+		}
+	}
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+	default:
+		return nil, fmt.Errorf("query %s %s to %s failed: %s", name, dns.TypeToString[qtype], server, dns.RcodeToString[resp.Rcode])
+	}
+
+	var records []dns.RR
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == qtype {
+			records = append(records, rr)
+		}
+	}
+
+	return records, nil
+}
+
+// WaitUntil polls every server until check accepts its answer for a name and
+// type, or ctx is done. Query failures are treated as a rejected answer.
+func (r *Resolver) WaitUntil(ctx context.Context, name string, qtype uint16, interval time.Duration, check func([]dns.RR) bool) error {
+	pending := append([]string(nil), r.servers...)
+
+	for {
+		var remaining []string
+		for _, server := range pending {
+			records, err := r.Lookup(server, name, qtype)
+			if err != nil || !check(records) {
+				remaining = append(remaining, server)
+			}
+		}
+
+		pending = remaining
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s %s not yet visible on %s", name, dns.TypeToString[qtype], strings.Join(pending, ", "))
+		case <-time.After(interval):
+		}
+	}
+}
+
+// TXTValues returns the text of TXT records, joining the strings of each
+func TXTValues(records []dns.RR) []string {
+	var values []string
+	for _, rr := range records {
+		if txt, ok := rr.(*dns.TXT); ok {
+			values = append(values, strings.Join(txt.Txt, ""))
+		}
+	}
+	return values
+}
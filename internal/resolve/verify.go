@@ -0,0 +1,73 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"ovh-dns-manager/internal/config"
+)
+
+// Mismatch describes a declared record that a server does not answer as
+// configured
+type Mismatch struct {
+	Record  config.DNSRecord
+	Server  string
+	Problem string
+}
+
+// VerifyZone resolves every record of a zone on each server and returns the
+// records answered with a missing value, a different target or another TTL.
+// Cached answers from recursive servers only count as a TTL mismatch when
+// they exceed the declared TTL.
+func (r *Resolver) VerifyZone(zone *config.DNSZone) []Mismatch {
+	var mismatches []Mismatch
+
+	for _, record := range zone.Records {
+		qtype := QueryType(record.Type)
+		if qtype == dns.TypeNone {
+			mismatches = append(mismatches, Mismatch{Record: record, Problem: "record type cannot be queried"})
+			continue
+		}
+
+		for _, server := range r.servers {
+			if problem := r.verifyRecord(server, zone.Domain, &record, qtype); problem != "" {
+				mismatches = append(mismatches, Mismatch{Record: record, Server: server, Problem: problem})
+			}
+		}
+	}
+
+	return mismatches
+}
+
+func (r *Resolver) verifyRecord(server, zone string, record *config.DNSRecord, qtype uint16) string {
+	answers, err := r.Lookup(server, RecordFQDN(zone, record.Name), qtype)
+	if err != nil {
+		return err.Error()
+	}
+
+	if len(answers) == 0 {
+		return "missing"
+	}
+
+	expected := ExpectedValue(zone, record)
+	values := AnswerValues(answers)
+	for i, value := range values {
+		if value != expected {
+			continue
+		}
+
+		ttl := int(answers[i].Header().Ttl)
+		declared := record.TTL
+		if declared == 0 {
+			declared = config.DefaultTTL
+		}
+
+		if ttl > declared || (!r.recursive && ttl != declared) {
+			return fmt.Sprintf("TTL is %d, expected %d", ttl, declared)
+		}
+		return ""
+	}
+
+	return fmt.Sprintf("wrong target: expected %s, got %s", expected, strings.Join(values, ", "))
+}
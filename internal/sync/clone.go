@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"log/slog"
+	"strings"
+
+	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/ovh"
+)
+
+// RewriteZone returns a copy of zone for domain to. Host name targets inside
+// the source domain are moved to the new domain; relative targets already
+// follow the zone and are kept as they are. The DNSSEC state is not copied,
+// so cloning never changes the signing of the target zone.
+func RewriteZone(zone *config.DNSZone, to string) *config.DNSZone {
+	from := strings.ToLower(strings.TrimSuffix(zone.Domain, "."))
+	to = strings.TrimSuffix(to, ".")
+
+	cloned := &config.DNSZone{
+		Domain:   to,
+		Defaults: zone.Defaults,
+		Records:  make([]config.DNSRecord, 0, len(zone.Records)),
+	}
+
+	for _, record := range zone.Records {
+		record.Target = rewriteTarget(record.Type, record.Target, from, to)
+		cloned.Records = append(cloned.Records, record)
+	}
+
+	return cloned
+}
+
+// rewriteTarget replaces the source domain in the host name part of a target
+func rewriteTarget(recordType, target, from, to string) string {
+	fields := strings.Fields(target)
+	if len(fields) == 0 {
+		return target
+	}
+
+	switch recordType {
+	case "CNAME", "NS", "PTR", "MX", "SRV":
+		// The host name is the last field, after an optional priority,
+		// weight and port
+		last := len(fields) - 1
+		fields[last] = rewriteHost(fields[last], from, to)
+		return strings.Join(fields, " ")
+	default:
+		return target
+	}
+}
+
+func rewriteHost(host, from, to string) string {
+	if !strings.HasSuffix(host, ".") {
+		return host
+	}
+
+	lower := strings.ToLower(host)
+	switch {
+	case lower == from+".":
+		return to + "."
+	case strings.HasSuffix(lower, "."+from+"."):
+		return host[:len(host)-len(from)-1] + to + "."
+	default:
+		return host
+	}
+}
+
+// CloneZone rewrites zone for domain to and syncs it to that domain. The
+// apex NS records are taken from the target zone, since OVH assigns name
+// servers per zone. It returns the zone that was applied.
+func (s *Syncer) CloneZone(zone *config.DNSZone, to string) (*config.DNSZone, *SyncResult, error) {
+	cloned := RewriteZone(zone, to)
+
+	records := cloned.Records[:0]
+	for _, record := range cloned.Records {
+		if config.IsApex(record.Name) && record.Type == "NS" {
+			continue
+		}
+		records = append(records, record)
+	}
+	cloned.Records = records
+
+	current, err := s.client.FindRecords(cloned.Domain, "", "NS")
+	if err != nil {
+		return cloned, &SyncResult{Zone: cloned.Domain, DryRun: s.dryRun}, err
+	}
+	for i := range current {
+		cloned.Records = append(cloned.Records, *ovh.ConvertOVHRecordToDNSRecord(&current[i]))
+	}
+
+	slog.Info("Cloning zone", "op", "clone", "zone", cloned.Domain, "source", zone.Domain, "records", len(cloned.Records))
+	result, err := s.SyncZone(cloned)
+	return cloned, result, err
+}
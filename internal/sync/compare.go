@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"log/slog"
+	"sort"
+
+	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/ovh"
+)
+
+// RecordDiff is a record present on both sides with different values
+type RecordDiff struct {
+	Left  config.DNSRecord
+	Right config.DNSRecord
+}
+
+// DNSSECDiff is the DNSSEC state of two zones declaring different ones
+type DNSSECDiff struct {
+	Left  bool
+	Right bool
+}
+
+// ZoneComparison lists the differences between two zones, matching records
+// by name and type and comparing them like SyncZone does
+type ZoneComparison struct {
+	Zone      string
+	OnlyLeft  []config.DNSRecord
+	OnlyRight []config.DNSRecord
+	Different []RecordDiff
+	// DNSSEC is set when both zones declare a DNSSEC state and they differ
+	DNSSEC *DNSSECDiff
+}
+
+func CompareZones(left, right *config.DNSZone) *ZoneComparison {
+	comparison := &ZoneComparison{Zone: left.Domain}
+
+	leftRecords := make(map[string]*config.DNSRecord)
+	for i := range left.Records {
+		leftRecords[ovh.RecordKey(&left.Records[i])] = &left.Records[i]
+	}
+
+	rightRecords := make(map[string]*config.DNSRecord)
+	for i := range right.Records {
+		rightRecords[ovh.RecordKey(&right.Records[i])] = &right.Records[i]
+	}
+
+	for key, record := range leftRecords {
+		other, exists := rightRecords[key]
+		if !exists {
+			comparison.OnlyLeft = append(comparison.OnlyLeft, *record)
+		} else if !ovh.RecordsEqual(record, other) {
+			comparison.Different = append(comparison.Different, RecordDiff{Left: *record, Right: *other})
+		}
+	}
+
+	for key, record := range rightRecords {
+		if _, exists := leftRecords[key]; !exists {
+			comparison.OnlyRight = append(comparison.OnlyRight, *record)
+		}
+	}
+
+	if left.DNSSEC != nil && right.DNSSEC != nil && *left.DNSSEC != *right.DNSSEC {
+		comparison.DNSSEC = &DNSSECDiff{Left: *left.DNSSEC, Right: *right.DNSSEC}
+	}
+
+	config.SortRecords(comparison.OnlyLeft)
+	config.SortRecords(comparison.OnlyRight)
+	sort.Slice(comparison.Different, func(i, j int) bool {
+		return ovh.RecordKey(&comparison.Different[i].Left) < ovh.RecordKey(&comparison.Different[j].Left)
+	})
+
+	return comparison
+}
+
+func (c *ZoneComparison) Equal() bool {
+	return len(c.OnlyLeft)+len(c.OnlyRight)+len(c.Different) == 0 && c.DNSSEC == nil
+}
+
+func (c *ZoneComparison) PrintSummary(leftName, rightName string) {
+	sides := []any{"op", "compare", "zone", c.Zone, "left", leftName, "right", rightName}
+
+	for _, record := range c.OnlyLeft {
+		slog.Info("Record only in left zone", append(sides, "key", ovh.RecordKey(&record),
+			"name", record.Name, "type", record.Type, "target", record.Target)...)
+	}
+	for _, record := range c.OnlyRight {
+		slog.Info("Record only in right zone", append(sides, "key", ovh.RecordKey(&record),
+			"name", record.Name, "type", record.Type, "target", record.Target)...)
+	}
+	for _, diff := range c.Different {
+		slog.Info("Record differs", append(sides, "key", ovh.RecordKey(&diff.Left),
+			"name", diff.Left.Name, "type", diff.Left.Type,
+			"left_target", diff.Left.Target, "left_ttl", diff.Left.TTL,
+			"right_target", diff.Right.Target, "right_ttl", diff.Right.TTL)...)
+	}
+	if c.DNSSEC != nil {
+		slog.Info("DNSSEC differs", append(sides, "left_dnssec", c.DNSSEC.Left, "right_dnssec", c.DNSSEC.Right)...)
+	}
+
+	if c.Equal() {
+		slog.Info("Zones are identical", sides...)
+		return
+	}
+
+	slog.Info("Summary", append(sides, "only_left", len(c.OnlyLeft), "only_right", len(c.OnlyRight),
+		"different", len(c.Different), "dnssec_differs", c.DNSSEC != nil)...)
+}
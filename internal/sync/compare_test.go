@@ -0,0 +1,26 @@
+package sync
+
+import (
+	"testing"
+
+	"ovh-dns-manager/internal/config"
+)
+
+func TestCompareZonesApex(t *testing.T) {
+	left := &config.DNSZone{Domain: "example.com", Records: []config.DNSRecord{
+		{Name: "@", Type: "A", Target: "192.0.2.1", TTL: 300},
+		{Name: "@", Type: "MX", Target: "mx1.example.com.", Priority: 10},
+	}}
+	right := &config.DNSZone{Domain: "example.com", Records: []config.DNSRecord{
+		{Name: "", Type: "A", Target: "192.0.2.1", TTL: 300},
+		{Name: "", Type: "MX", Target: "mx2.example.com.", Priority: 10},
+	}}
+
+	comparison := CompareZones(left, right)
+	if len(comparison.OnlyLeft) != 0 || len(comparison.OnlyRight) != 0 {
+		t.Errorf("apex records reported on one side only: left %+v, right %+v", comparison.OnlyLeft, comparison.OnlyRight)
+	}
+	if len(comparison.Different) != 1 || comparison.Different[0].Left.Type != "MX" {
+		t.Errorf("different %+v, want the MX record", comparison.Different)
+	}
+}
@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"errors"
+	"log/slog"
+
+	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/ovh"
+)
+
+// missingComment marks the records of a zone file that are not in OVH
+const missingComment = "not found in OVH zone (pull)"
+
+// PullResult lists the changes merged from the live zone into a zone file
+type PullResult struct {
+	Zone    string
+	Added   []config.DNSRecord
+	Updated []config.DNSRecord
+	Removed []config.DNSRecord
+	// DNSSEC is the live DNSSEC state written to the file, when it differed
+	DNSSEC *bool
+	// Skipped lists drifted records produced by variables or record groups,
+	// and live records sharing a name and type, which have to be edited by
+	// hand
+	Skipped []config.DNSRecord
+}
+
+// PullZone merges the live records of the zone described by file back into
+// it: records missing from the file are appended, changed ones are updated in
+// place and records no longer present in OVH are either annotated or, with
+// prune, removed. Only the in-memory document is modified; saving it is left
+// to the caller.
+func (s *Syncer) PullZone(file *config.ZoneFile, prune bool) (*PullResult, error) {
+	result := &PullResult{}
+
+	zone, err := file.Zone()
+	if err != nil {
+		return result, err
+	}
+
+	result.Zone = zone.Domain
+
+	liveZone, err := s.ExportZone(zone.Domain)
+	if err != nil {
+		return result, err
+	}
+
+	declared := make(map[string]*config.DNSRecord)
+	for i := range zone.Records {
+		declared[ovh.RecordKey(&zone.Records[i])] = &zone.Records[i]
+	}
+
+	live := make(map[string]*config.DNSRecord)
+	liveCount := make(map[string]int)
+	for i := range liveZone.Records {
+		key := ovh.RecordKey(&liveZone.Records[i])
+		live[key] = &liveZone.Records[i]
+		liveCount[key]++
+	}
+
+	for i := range liveZone.Records {
+		current := liveZone.Records[i]
+		key := ovh.RecordKey(&current)
+
+		// A zone file entry holds a single record per name and type
+		if liveCount[key] > 1 {
+			slog.Warn("Skipping record with several live values", "op", "skip", "zone", zone.Domain, "key", key,
+				"name", current.Name, "type", current.Type, "target", current.Target, "values", liveCount[key])
+			result.Skipped = append(result.Skipped, current)
+			continue
+		}
+
+		existing, exists := declared[key]
+		if exists && ovh.RecordsEqual(existing, &current) {
+			continue
+		}
+
+		if _, err := file.SetRecord(&current); err != nil {
+			if errors.Is(err, config.ErrTemplated) {
+				attrs := []any{"op", "skip", "zone", zone.Domain, "key", key, "name", current.Name, "type", current.Type, "target", current.Target}
+				if exists {
+					attrs = append(attrs, "declared", existing.Target)
+				}
+				slog.Warn("Skipping templated record", attrs...)
+				result.Skipped = append(result.Skipped, current)
+				continue
+			}
+			return result, err
+		}
+
+		if exists {
+			slog.Info("Pulling updated record", "op", "update", "zone", zone.Domain, "key", key,
+				"name", current.Name, "type", current.Type, "target", current.Target, "previous", existing.Target)
+			result.Updated = append(result.Updated, current)
+		} else {
+			slog.Info("Pulling new record", "op", "create", "zone", zone.Domain, "key", key,
+				"name", current.Name, "type", current.Type, "target", current.Target)
+			result.Added = append(result.Added, current)
+		}
+	}
+
+	for _, record := range zone.Records {
+		key := ovh.RecordKey(&record)
+		if _, exists := live[key]; exists {
+			continue
+		}
+		if !prune && file.RecordComment(record.Name, record.Type) == missingComment {
+			continue
+		}
+		attrs := []any{"zone", zone.Domain, "key", key, "name", record.Name, "type", record.Type, "target", record.Target}
+
+		if prune {
+			if _, err := file.RemoveRecord(record.Name, record.Type, ""); err != nil {
+				if errors.Is(err, config.ErrTemplated) {
+					slog.Warn("Skipping templated record missing from OVH", append([]any{"op", "skip"}, attrs...)...)
+					result.Skipped = append(result.Skipped, record)
+					continue
+				}
+				return result, err
+			}
+			slog.Info("Removing record missing from OVH", append([]any{"op", "delete"}, attrs...)...)
+		} else {
+			slog.Info("Marking record missing from OVH", append([]any{"op", "annotate"}, attrs...)...)
+			file.AnnotateRecord(record.Name, record.Type, missingComment)
+		}
+		result.Removed = append(result.Removed, record)
+	}
+
+	// DNSSEC is only pulled into files that manage it, when its live state is
+	// known
+	if zone.DNSSEC != nil {
+		if active := s.LiveDNSSEC(zone.Domain); active != nil && *active != *zone.DNSSEC {
+			slog.Info("Pulling DNSSEC state", "op", "dnssec", "zone", zone.Domain, "dnssec", *active)
+			if err := file.SetDNSSEC(*active); err != nil {
+				return result, err
+			}
+			result.DNSSEC = active
+		}
+	}
+
+	return result, nil
+}
+
+func (r *PullResult) HasChanges() bool {
+	return len(r.Added)+len(r.Updated)+len(r.Removed) > 0 || r.DNSSEC != nil
+}
+
+func (r *PullResult) PrintSummary() {
+	if !r.HasChanges() && len(r.Skipped) == 0 {
+		slog.Info("Zone file is up to date", "op", "summary", "zone", r.Zone)
+		return
+	}
+
+	attrs := []any{"op", "summary", "zone", r.Zone, "added", len(r.Added), "updated", len(r.Updated), "removed", len(r.Removed)}
+	if r.DNSSEC != nil {
+		attrs = append(attrs, "dnssec", *r.DNSSEC)
+	}
+	slog.Info("Summary", attrs...)
+
+	if len(r.Skipped) > 0 {
+		slog.Warn("Skipped records, update them by hand", "op", "summary", "zone", r.Zone, "skipped", len(r.Skipped))
+	}
+}
@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/ovh"
+)
+
+// newTestClient returns a client for an in-process OVH API serving the
+// given live records and DNSSEC status
+func newTestClient(t *testing.T, dnssecStatus string, records ...config.OVHRecord) *ovh.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /1.0/domain/zone/{zone}/...
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/1.0/"), "/")
+		if r.Method != http.MethodGet || len(parts) < 4 || parts[0] != "domain" || parts[1] != "zone" {
+			http.Error(w, `{"message":"not implemented"}`, http.StatusNotImplemented)
+			return
+		}
+
+		switch {
+		case parts[3] == "dnssec":
+			json.NewEncoder(w).Encode(config.OVHDNSSEC{Status: dnssecStatus})
+		case parts[3] == "record" && len(parts) == 4:
+			ids := []int64{}
+			for _, record := range records {
+				ids = append(ids, record.ID)
+			}
+			json.NewEncoder(w).Encode(ids)
+		case parts[3] == "record":
+			id, _ := strconv.ParseInt(parts[4], 10, 64)
+			for _, record := range records {
+				if record.ID == id {
+					json.NewEncoder(w).Encode(record)
+					return
+				}
+			}
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		default:
+			http.Error(w, `{"message":"not implemented"}`, http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ovh.NewClient(&config.OVHCredentials{
+		Endpoint:          server.URL + "/1.0",
+		ApplicationKey:    "ak",
+		ApplicationSecret: "as",
+		ConsumerKey:       "ck",
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+// writeZoneFile writes content to a zone file in a temporary directory and
+// opens it
+func writeZoneFile(t *testing.T, content string) *config.ZoneFile {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "zone.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write zone file: %v", err)
+	}
+	file, err := config.OpenZoneFile(path)
+	if err != nil {
+		t.Fatalf("OpenZoneFile: %v", err)
+	}
+	return file
+}
+
+func liveRecord(id int64, subDomain, fieldType, target string) config.OVHRecord {
+	return config.OVHRecord{ID: id, Zone: "example.com", SubDomain: subDomain, FieldType: fieldType, Target: target, TTL: config.DefaultTTL}
+}
+
+func TestPullZoneApex(t *testing.T) {
+	for _, prune := range []bool{false, true} {
+		t.Run(fmt.Sprintf("prune=%v", prune), func(t *testing.T) {
+			client := newTestClient(t, ovh.DNSSECDisabled,
+				liveRecord(1, "", "A", "192.0.2.2"),
+				liveRecord(2, "www", "CNAME", "example.com."),
+			)
+			file := writeZoneFile(t, `domain: example.com
+records:
+  - name: "@"
+    type: A
+    target: 192.0.2.1
+  - name: www
+    type: CNAME
+    target: example.com.
+`)
+
+			result, err := NewSyncer(client, false).PullZone(file, prune)
+			if err != nil {
+				t.Fatalf("PullZone: %v", err)
+			}
+			if len(result.Added) != 0 || len(result.Updated) != 1 || len(result.Removed) != 0 {
+				t.Fatalf("added %d, updated %d, removed %d, want 0, 1, 0", len(result.Added), len(result.Updated), len(result.Removed))
+			}
+
+			zone, err := file.Zone()
+			if err != nil {
+				t.Fatalf("Zone: %v", err)
+			}
+			if len(zone.Records) != 2 {
+				t.Fatalf("got %d records, want 2: %+v", len(zone.Records), zone.Records)
+			}
+			apex := zone.Records[0]
+			if apex.Name != "@" || apex.Target != "192.0.2.2" {
+				t.Errorf("apex record = %+v, want @ A 192.0.2.2", apex)
+			}
+			if comment := file.RecordComment("@", "A"); comment != "" {
+				t.Errorf("apex record annotated %q", comment)
+			}
+
+			// A second pull finds nothing left to do
+			result, err = NewSyncer(client, false).PullZone(file, prune)
+			if err != nil {
+				t.Fatalf("second PullZone: %v", err)
+			}
+			if result.HasChanges() {
+				t.Errorf("second pull has changes: %+v", result)
+			}
+		})
+	}
+}
+
+func TestPullZoneTemplatedApex(t *testing.T) {
+	client := newTestClient(t, ovh.DNSSECDisabled,
+		liveRecord(1, "", "TXT", "v=spf1 -all"),
+		liveRecord(2, "", "MX", "10 mx2.example.com."),
+	)
+	file := writeZoneFile(t, `domain: example.com
+groups:
+  mail:
+    - name: "@"
+      type: MX
+      target: mx1.example.com.
+      priority: 10
+records:
+  - group: mail
+    name: "@"
+`)
+
+	result, err := NewSyncer(client, false).PullZone(file, false)
+	if err != nil {
+		t.Fatalf("PullZone: %v", err)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Type != "MX" {
+		t.Errorf("skipped %+v, want the MX record", result.Skipped)
+	}
+	if len(result.Added) != 1 || result.Added[0].Type != "TXT" {
+		t.Errorf("added %+v, want the TXT record", result.Added)
+	}
+}
@@ -0,0 +1,128 @@
+package sync
+
+import (
+	"fmt"
+	"log/slog"
+
+	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/ovh"
+)
+
+// GetRecords returns the live records matching a name and, when recordType
+// is not empty, a type
+func (s *Syncer) GetRecords(domain, name, recordType string) ([]config.DNSRecord, error) {
+	ovhRecords, err := s.client.FindRecords(domain, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]config.DNSRecord, 0, len(ovhRecords))
+	for i := range ovhRecords {
+		records = append(records, *ovh.ConvertOVHRecordToDNSRecord(&ovhRecords[i]))
+	}
+	config.SortRecords(records)
+
+	return records, nil
+}
+
+// AddRecord creates a single record, refusing to add a second record with
+// the same name and type since apply would not be able to tell them apart
+func (s *Syncer) AddRecord(domain string, record *config.DNSRecord) error {
+	if err := config.ValidateDNSRecord(record); err != nil {
+		return err
+	}
+
+	existing, err := s.client.FindRecords(domain, record.Name, record.Type)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("record %s already exists (use record set to update it)", ovh.RecordKey(record))
+	}
+
+	slog.Info("Creating record", "op", "create", "zone", domain, "key", ovh.RecordKey(record),
+		"name", record.Name, "type", record.Type, "target", record.Target, "dry_run", s.dryRun)
+	if s.dryRun {
+		return nil
+	}
+
+	if _, err := s.client.CreateRecord(domain, ovh.ConvertDNSRecordToOVHCreate(record)); err != nil {
+		return fmt.Errorf("failed to create record %s: %w", ovh.RecordKey(record), err)
+	}
+
+	return s.refresh(domain)
+}
+
+// SetRecord creates a record or updates the existing record with the same
+// name and type. It reports whether anything changed.
+func (s *Syncer) SetRecord(domain string, record *config.DNSRecord) (bool, error) {
+	if err := config.ValidateDNSRecord(record); err != nil {
+		return false, err
+	}
+
+	existing, err := s.client.FindRecords(domain, record.Name, record.Type)
+	if err != nil {
+		return false, err
+	}
+	if len(existing) > 1 {
+		return false, fmt.Errorf("%d records match %s, delete the extra ones first", len(existing), ovh.RecordKey(record))
+	}
+
+	if len(existing) == 0 {
+		return true, s.AddRecord(domain, record)
+	}
+
+	current := ovh.ConvertOVHRecordToDNSRecord(&existing[0])
+	if ovh.RecordsEqual(record, current) {
+		slog.Info("Record is already up to date", "op", "keep", "zone", domain, "key", ovh.RecordKey(record), "id", existing[0].ID)
+		return false, nil
+	}
+
+	slog.Info("Updating record", "op", "update", "zone", domain, "key", ovh.RecordKey(record), "id", existing[0].ID,
+		"name", record.Name, "type", record.Type, "target", record.Target, "previous", current.Target, "dry_run", s.dryRun)
+	if s.dryRun {
+		return true, nil
+	}
+
+	if err := s.client.UpdateRecord(domain, existing[0].ID, ovh.ConvertDNSRecordToOVHUpdate(record)); err != nil {
+		return false, fmt.Errorf("failed to update record %s: %w", ovh.RecordKey(record), err)
+	}
+
+	return true, s.refresh(domain)
+}
+
+// DeleteRecord deletes the records matching a name and type, restricted to
+// a given target when target is not empty. It returns the deleted records.
+func (s *Syncer) DeleteRecord(domain, name, recordType, target string) ([]config.OVHRecord, error) {
+	existing, err := s.client.FindRecords(domain, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []config.OVHRecord
+	for _, current := range existing {
+		if target != "" && current.Target != target {
+			continue
+		}
+
+		slog.Info("Deleting record", "op", "delete", "zone", domain, "key", ovh.OVHRecordKey(&current), "id", current.ID,
+			"name", current.SubDomain, "type", current.FieldType, "target", current.Target, "dry_run", s.dryRun)
+		if !s.dryRun {
+			if err := s.client.DeleteRecord(domain, current.ID); err != nil {
+				return deleted, fmt.Errorf("failed to delete record %s: %w", ovh.OVHRecordKey(&current), err)
+			}
+		}
+		deleted = append(deleted, current)
+	}
+
+	if len(deleted) == 0 || s.dryRun {
+		return deleted, nil
+	}
+
+	return deleted, s.refresh(domain)
+}
+
+func (s *Syncer) refresh(domain string) error {
+	slog.Info("Refreshing DNS zone", "op", "refresh", "zone", domain)
+	return s.client.RefreshZone(domain)
+}
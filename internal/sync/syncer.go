@@ -2,10 +2,11 @@ package sync
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"ovh-dns-manager/internal/config"
 	"ovh-dns-manager/internal/ovh"
+	"ovh-dns-manager/internal/resolve"
 )
 
 type Syncer struct {
@@ -14,10 +15,14 @@ type Syncer struct {
 }
 
 type SyncResult struct {
+	Zone    string
+	DryRun  bool
 	Created []config.DNSRecord
 	Updated []config.DNSRecord
 	Deleted []config.OVHRecord
 	Errors  []error
+	// DNSSEC is the DNSSEC state the zone was switched to, when it changed
+	DNSSEC *bool
 }
 
 func NewSyncer(client *ovh.Client, dryRun bool) *Syncer {
@@ -28,7 +33,7 @@ func NewSyncer(client *ovh.Client, dryRun bool) *Syncer {
 }
 
 func (s *Syncer) SyncZone(zone *config.DNSZone) (*SyncResult, error) {
-	result := &SyncResult{}
+	result := &SyncResult{Zone: zone.Domain, DryRun: s.dryRun}
 
 	currentRecords, err := s.client.GetZoneRecords(zone.Domain)
 	if err != nil {
@@ -50,7 +55,8 @@ func (s *Syncer) SyncZone(zone *config.DNSZone) (*SyncResult, error) {
 	for key, desired := range desiredRecords {
 		current, exists := currentRecordsMap[key]
 		if !exists {
-			log.Printf("Creating record: %s %s -> %s", desired.Name, desired.Type, desired.Target)
+			slog.Info("Creating record", "op", "create", "zone", zone.Domain, "key", key,
+				"name", desired.Name, "type", desired.Type, "target", desired.Target, "dry_run", s.dryRun)
 			if !s.dryRun {
 				createRecord := ovh.ConvertDNSRecordToOVHCreate(desired)
 				_, err := s.client.CreateRecord(zone.Domain, createRecord)
@@ -63,7 +69,8 @@ func (s *Syncer) SyncZone(zone *config.DNSZone) (*SyncResult, error) {
 		} else {
 			currentDNS := ovh.ConvertOVHRecordToDNSRecord(current)
 			if !ovh.RecordsEqual(desired, currentDNS) {
-				log.Printf("Updating record: %s %s -> %s (was %s)", desired.Name, desired.Type, desired.Target, currentDNS.Target)
+				slog.Info("Updating record", "op", "update", "zone", zone.Domain, "key", key, "id", current.ID,
+					"name", desired.Name, "type", desired.Type, "target", desired.Target, "previous", currentDNS.Target, "dry_run", s.dryRun)
 				if !s.dryRun {
 					updateRecord := ovh.ConvertDNSRecordToOVHUpdate(desired)
 					err := s.client.UpdateRecord(zone.Domain, current.ID, updateRecord)
@@ -79,7 +86,13 @@ func (s *Syncer) SyncZone(zone *config.DNSZone) (*SyncResult, error) {
 
 	for key, current := range currentRecordsMap {
 		if _, exists := desiredRecords[key]; !exists {
-			log.Printf("Deleting record: %s %s -> %s (ID: %d)", current.SubDomain, current.FieldType, current.Target, current.ID)
+			if isUnmanaged(current) {
+				slog.Info("Keeping record not managed by apply", "op", "keep", "zone", zone.Domain, "key", key, "id", current.ID,
+					"name", current.SubDomain, "type", current.FieldType, "target", current.Target)
+				continue
+			}
+			slog.Info("Deleting record", "op", "delete", "zone", zone.Domain, "key", key, "id", current.ID,
+				"name", current.SubDomain, "type", current.FieldType, "target", current.Target, "dry_run", s.dryRun)
 			if !s.dryRun {
 				err := s.client.DeleteRecord(zone.Domain, current.ID)
 				if err != nil {
@@ -91,8 +104,15 @@ func (s *Syncer) SyncZone(zone *config.DNSZone) (*SyncResult, error) {
 		}
 	}
 
-	if result.HasChanges() && !s.dryRun {
-		log.Printf("Refreshing DNS zone %s", zone.Domain)
+	if zone.DNSSEC != nil {
+		if err := s.syncDNSSEC(zone.Domain, *zone.DNSSEC, result); err != nil {
+			result.Errors = append(result.Errors, err)
+		}
+	}
+
+	// DNSSEC changes apply without a refresh
+	if len(result.Created)+len(result.Updated)+len(result.Deleted) > 0 && !s.dryRun {
+		slog.Info("Refreshing DNS zone", "op", "refresh", "zone", zone.Domain)
 		if err := s.client.RefreshZone(zone.Domain); err != nil {
 			result.Errors = append(result.Errors, err)
 		}
@@ -101,6 +121,49 @@ func (s *Syncer) SyncZone(zone *config.DNSZone) (*SyncResult, error) {
 	return result, nil
 }
 
+// SyncDNSSEC enables or disables DNSSEC signing of a zone, as SyncZone does
+// for a zone file declaring it
+func (s *Syncer) SyncDNSSEC(domain string, enabled bool) (*SyncResult, error) {
+	result := &SyncResult{Zone: domain, DryRun: s.dryRun}
+	return result, s.syncDNSSEC(domain, enabled, result)
+}
+
+// syncDNSSEC enables or disables DNSSEC signing when the zone is not in the
+// declared state. A change already in progress counts as done, while a change
+// in progress the other way is reported, since OVH accepts no new change
+// until it completes.
+func (s *Syncer) syncDNSSEC(domain string, enabled bool, result *SyncResult) error {
+	current, err := s.client.GetDNSSEC(domain)
+	if err != nil {
+		return fmt.Errorf("failed to get DNSSEC state: %w", err)
+	}
+	if ovh.DNSSECActive(current) == enabled {
+		return nil
+	}
+	if current.Status == ovh.DNSSECEnableInProgress || current.Status == ovh.DNSSECDisableInProgress {
+		return fmt.Errorf("DNSSEC of zone %s is %s, retry once it completes", domain, current.Status)
+	}
+
+	if enabled {
+		slog.Info("Enabling DNSSEC", "op", "dnssec", "zone", domain, "status", current.Status, "dry_run", s.dryRun)
+		if !s.dryRun {
+			if err := s.client.EnableDNSSEC(domain); err != nil {
+				return fmt.Errorf("failed to enable DNSSEC: %w", err)
+			}
+		}
+	} else {
+		slog.Info("Disabling DNSSEC", "op", "dnssec", "zone", domain, "status", current.Status, "dry_run", s.dryRun)
+		if !s.dryRun {
+			if err := s.client.DisableDNSSEC(domain); err != nil {
+				return fmt.Errorf("failed to disable DNSSEC: %w", err)
+			}
+		}
+	}
+
+	result.DNSSEC = &enabled
+	return nil
+}
+
 func (s *Syncer) ExportZone(domain string) (*config.DNSZone, error) {
 	records, err := s.client.GetZoneRecords(domain)
 	if err != nil {
@@ -113,15 +176,58 @@ func (s *Syncer) ExportZone(domain string) (*config.DNSZone, error) {
 	}
 
 	for _, ovhRecord := range records {
+		if isUnmanaged(&ovhRecord) {
+			continue
+		}
 		dnsRecord := ovh.ConvertOVHRecordToDNSRecord(&ovhRecord)
 		zone.Records = append(zone.Records, *dnsRecord)
 	}
 
+	// OVH returns record IDs in no particular order, sort for diff-friendly exports
+	config.SortRecords(zone.Records)
+
 	return zone, nil
 }
 
+// LiveDNSSEC returns whether DNSSEC signing of a zone is active, or nil when
+// the state cannot be read, such as with credentials limited to the records
+func (s *Syncer) LiveDNSSEC(domain string) *bool {
+	dnssec, err := s.client.GetDNSSEC(domain)
+	if err != nil {
+		slog.Warn("DNSSEC state unknown", "op", "dnssec", "zone", domain, "error", err)
+		return nil
+	}
+	active := ovh.DNSSECActive(dnssec)
+	return &active
+}
+
+// isUnmanaged reports whether a live record is maintained by another
+// command and must be left alone by declarative syncs and exports.
+// DynHost records are updated by the dynhost command and DNS-01 challenges
+// come and go with certificate renewals.
+func isUnmanaged(record *config.OVHRecord) bool {
+	return record.FieldType == "DYNHOST" || config.IsACMEChallenge(record.SubDomain, record.FieldType)
+}
+
 func (r *SyncResult) HasChanges() bool {
-	return len(r.Created)+len(r.Updated)+len(r.Deleted) > 0
+	return len(r.Created)+len(r.Updated)+len(r.Deleted) > 0 || r.DNSSEC != nil
+}
+
+// Expectations lists the state the authoritative servers should reach once
+// the applied changes are live
+func (r *SyncResult) Expectations() []resolve.Expectation {
+	var expectations []resolve.Expectation
+	for _, record := range r.Created {
+		expectations = append(expectations, resolve.Expectation{Record: record})
+	}
+	for _, record := range r.Updated {
+		expectations = append(expectations, resolve.Expectation{Record: record})
+	}
+	for i := range r.Deleted {
+		record := ovh.ConvertOVHRecordToDNSRecord(&r.Deleted[i])
+		expectations = append(expectations, resolve.Expectation{Record: *record, Absent: true})
+	}
+	return expectations
 }
 
 func (r *SyncResult) HasErrors() bool {
@@ -129,19 +235,20 @@ func (r *SyncResult) HasErrors() bool {
 }
 
 func (r *SyncResult) PrintSummary() {
-	if !r.HasChanges() {
-		log.Println("No changes needed")
+	if !r.HasChanges() && !r.HasErrors() {
+		slog.Info("No changes needed", "op", "summary", "zone", r.Zone)
 		return
 	}
 
-	log.Printf("Summary: %d created, %d updated, %d deleted",
-		len(r.Created), len(r.Updated), len(r.Deleted))
+	attrs := []any{"op", "summary", "zone", r.Zone, "dry_run", r.DryRun,
+		"created", len(r.Created), "updated", len(r.Updated), "deleted", len(r.Deleted), "errors", len(r.Errors)}
+	if r.DNSSEC != nil {
+		attrs = append(attrs, "dnssec", *r.DNSSEC)
+	}
+	slog.Info("Summary", attrs...)
 
-	if r.HasErrors() {
-		log.Printf("Errors: %d", len(r.Errors))
-		for _, err := range r.Errors {
-			log.Printf("  - %v", err)
-		}
+	for _, err := range r.Errors {
+		slog.Error("Change failed", "op", "summary", "zone", r.Zone, "error", err)
 	}
 }
 
@@ -1,32 +1,140 @@
 package main
 
+//go:generate go run . schema --output zone.schema.json
+
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"ovh-dns-manager/internal/acme"
 	"ovh-dns-manager/internal/config"
+	"ovh-dns-manager/internal/dynhost"
 	"ovh-dns-manager/internal/ovh"
+	"ovh-dns-manager/internal/redact"
+	"ovh-dns-manager/internal/resolve"
 	"ovh-dns-manager/internal/sync"
 )
 
 var (
 	credentialsFile string
+	profile         string
+	debug           bool
+	logLevel        string
+	logFormat       string
+	traceFile       string
 	configFile      string
 	domain          string
 	outputFile      string
+	groupComments   bool
+	noDefaults      bool
 	dryRun          bool
+	prune           bool
+	recordType      string
+	recordTarget    string
+	recordTTL       int
+	challengeTTL    int
+	recordPriority  int
+	ipURL           string
+	ipInterface     string
+	ipv6            bool
+	interval        time.Duration
+	wait            bool
+	waitTimeout     time.Duration
+	servers         []string
+	authoritative   bool
+	cloneFrom       string
+	cloneFromFile   string
+	cloneTo         string
+	rewrite         bool
+	loginZones      []string
+	loginEndpoint   string
+	applicationKey  string
+	appSecret       string
+	loginTimeout    time.Duration
+	configFiles     []string
+	checkZones      []string
 	version         string = "dev"
 )
 
+// tracer, when set, records the HTTP exchanges with the OVH API to traceOutput
+var (
+	tracer      *ovh.Tracer
+	traceOutput *os.File
+)
+
+// setupLogging installs the default slog logger and the tracer from the
+// global flags
+func setupLogging(cmd *cobra.Command, args []string) error {
+	level := slog.LevelInfo
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q (use debug, info, warn or error)", logLevel)
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch logFormat {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, options)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, options)))
+	default:
+		return fmt.Errorf("invalid log format %q (use text or json)", logFormat)
+	}
+
+	if traceFile != "" {
+		file, err := os.OpenFile(traceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open trace file: %w", err)
+		}
+		traceOutput = file
+		tracer = ovh.NewTracer(file)
+	}
+
+	return nil
+}
+
+// closeTraceFile closes the trace file opened by setupLogging, if any
+func closeTraceFile() {
+	if traceOutput == nil {
+		return
+	}
+	if err := traceOutput.Close(); err != nil {
+		slog.Warn("Failed to close trace file", "file", traceFile, "error", err)
+	}
+	traceOutput = nil
+}
+
 // setupOVHClient loads credentials and creates OVH client
-func setupOVHClient(credentialsFile string) (*ovh.Client, error) {
-	creds, err := config.LoadOVHCredentials(credentialsFile)
+func setupOVHClient(credentialsFile, profile string) (*ovh.Client, error) {
+	creds, err := config.LoadOVHCredentials(credentialsFile, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOVHClient(creds)
+}
+
+// newOVHClient creates an OVH client logging and tracing as requested by the
+// global flags
+func newOVHClient(creds *config.OVHCredentials) (*ovh.Client, error) {
+	client, err := ovh.NewClient(creds)
 	if err != nil {
 		return nil, err
 	}
 
-	return ovh.NewClient(creds)
+	if tracer != nil {
+		client.SetTracer(tracer)
+	}
+	return client, nil
 }
 
 // resolveValueWithEnvFallback resolves a flag value with environment variable fallback
@@ -41,10 +149,11 @@ func resolveValueWithEnvFallback(flagValue, envValue, flagName, envVarName strin
 }
 
 var rootCmd = &cobra.Command{
-	Use:     "ovh-dns-manager",
-	Short:   "Manage OVH DNS zones via YAML configuration",
-	Long:    "A tool to export and apply DNS zone configurations to OVH using YAML files",
-	Version: version,
+	Use:               "ovh-dns-manager",
+	Short:             "Manage OVH DNS zones via YAML configuration",
+	Long:              "A tool to export and apply DNS zone configurations to OVH using YAML, JSON or TOML files",
+	Version:           version,
+	PersistentPreRunE: setupLogging,
 }
 
 var exportCmd = &cobra.Command{
@@ -61,13 +170,176 @@ var applyCmd = &cobra.Command{
 	RunE:  runApply,
 }
 
+var pullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Merge live DNS records back into a YAML file",
+	Long:  "Fetch DNS records from OVH and merge changes made outside this tool into an existing YAML configuration, keeping its order and comments",
+	RunE:  runPull,
+}
+
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Manage a single DNS record",
+	Long:  "Add, update, delete or show a single DNS record directly through the OVH API, optionally keeping a YAML configuration file in sync",
+}
+
+var recordAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a DNS record",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRecordAdd,
+}
+
+var recordSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Create or update a DNS record",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRecordSet,
+}
+
+var recordDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete DNS records by name and type",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRecordDelete,
+}
+
+var recordGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Show live DNS records for a name",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRecordGet,
+}
+
+var dynhostCmd = &cobra.Command{
+	Use:   "dynhost",
+	Short: "Keep a DynHost record pointed at the current public IP",
+}
+
+var dynhostUpdateCmd = &cobra.Command{
+	Use:   "update <subdomain>",
+	Short: "Update a DynHost record with the current public IP",
+	Long:  "Detect the current public IP and update the DynHost record (or AAAA record with --ipv6) when it changed, once or in a loop with --interval",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDynHost,
+}
+
+var dynhostLoginsCmd = &cobra.Command{
+	Use:   "logins",
+	Short: "List DynHost logins of a zone",
+	Args:  cobra.NoArgs,
+	RunE:  runDynHostLogins,
+}
+
+var dnssecCmd = &cobra.Command{
+	Use:   "dnssec",
+	Short: "Show or change DNSSEC signing of a zone",
+	Long:  "Query, enable or disable DNSSEC signing of a zone. Zone files can declare the state with dnssec: true or false, which apply enforces.",
+}
+
+var dnssecStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print the DNSSEC state of a zone",
+	Args:  cobra.NoArgs,
+	RunE:  runDNSSECStatus,
+}
+
+var dnssecEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enable DNSSEC signing of a zone",
+	Args:  cobra.NoArgs,
+	RunE:  runDNSSECEnable,
+}
+
+var dnssecDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Disable DNSSEC signing of a zone",
+	Args:  cobra.NoArgs,
+	RunE:  runDNSSECDisable,
+}
+
+var acmeCmd = &cobra.Command{
+	Use:   "acme",
+	Short: "DNS-01 challenge hook for ACME clients",
+	Long:  "Create and delete _acme-challenge TXT records for certbot, lego, acme.sh and other ACME clients. Arguments default to the CERTBOT_DOMAIN and CERTBOT_VALIDATION environment variables.",
+}
+
+var acmePresentCmd = &cobra.Command{
+	Use:   "present [fqdn] [value]",
+	Short: "Create a challenge TXT record",
+	Args:  cobra.RangeArgs(0, 2),
+	RunE:  runACMEPresent,
+}
+
+var acmeCleanupCmd = &cobra.Command{
+	Use:   "cleanup [fqdn] [value]",
+	Short: "Delete a challenge TXT record",
+	Args:  cobra.RangeArgs(0, 2),
+	RunE:  runACMECleanup,
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that DNS answers match a YAML file",
+	Long:  "Resolve every record of a YAML configuration against the zone's authoritative name servers, or the given DNS servers, and report missing records, wrong targets and TTLs",
+	RunE:  runVerify,
+}
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone",
+	Short: "Copy a DNS zone to another domain",
+	Long:  "Copy the records of a live domain or YAML file to another domain, moving host names of the source domain to the target domain, and apply them through the same sync as apply",
+	RunE:  runClone,
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Create and save a consumer key",
+	Long:  "Request a consumer key with the access rules this tool needs, wait until it is validated in the browser and write it to the credentials file",
+	Args:  cobra.NoArgs,
+	RunE:  runLogin,
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:     "whoami",
+	Aliases: []string{"check-credentials"},
+	Short:   "Show who the credentials belong to and check their access",
+	Long:    "Show the account, application and expiration of the consumer key, and check that its access rules allow managing the zones of the given configuration files",
+	Args:    cobra.NoArgs,
+	RunE:    runWhoami,
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of zone files",
+	Long:  "Print the JSON Schema describing zone files, for editor completion and validation in CI",
+	Args:  cobra.NoArgs,
+	RunE:  runSchema,
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <zone> <zone>",
+	Short: "Compare two DNS zones",
+	Long:  "Show the differences between two zones, each given as a YAML file or a live domain name",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runCompare,
+}
+
 func init() {
 	credentialsPath, envDomain, configPath := config.LoadAppConfig()
 	
 	rootCmd.PersistentFlags().StringVarP(&credentialsFile, "credentials", "c", credentialsPath, "OVH credentials file")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Log every OVH API call, same as --log-level debug")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum level of log messages: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of log messages: text or json")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "Append the HTTP exchanges with the OVH API to this file, with credentials redacted")
+	rootCmd.PersistentFlags().BoolVar(&config.StrictPermissions, "strict-perms", false, "Refuse credentials files readable by other users instead of warning")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Credentials profile from the credentials file or ovh.conf (default: $OVH_PROFILE)")
 	
 	exportCmd.Flags().StringVarP(&domain, "domain", "d", "", "Domain to export (required)")
-	exportCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output YAML file (default: {domain}.yaml)")
+	exportCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file, in YAML, JSON or TOML by extension (default: {domain}.yaml)")
+	exportCmd.Flags().BoolVar(&groupComments, "comments", false, "Add a comment before each group of records sharing a name")
+	exportCmd.Flags().BoolVar(&noDefaults, "no-defaults", false, "Write the TTL of every record instead of a defaults block")
 	
 	// Make domain flag not required if OVH_DOMAIN env var is set
 	if envDomain == "" {
@@ -76,14 +348,104 @@ func init() {
 
 	applyCmd.Flags().StringVarP(&configFile, "config", "f", "", "DNS configuration YAML file (required)")
 	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without applying them")
+	applyCmd.Flags().BoolVar(&wait, "wait", false, "Wait until the authoritative name servers answer with the applied changes")
+	applyCmd.Flags().DurationVar(&waitTimeout, "wait-timeout", 5*time.Minute, "Maximum time to wait with --wait")
 	
 	// Make config flag not required if OVH_CONFIG_PATH env var is set
 	if configPath == "" {
 		applyCmd.MarkFlagRequired("config")
 	}
 
+	pullCmd.Flags().StringVarP(&configFile, "config", "f", "", "DNS configuration YAML file to update (required)")
+	pullCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without writing the file")
+	pullCmd.Flags().BoolVar(&prune, "prune", false, "Remove records missing from OVH instead of marking them with a comment")
+
+	if configPath == "" {
+		pullCmd.MarkFlagRequired("config")
+	}
+
+	for _, cmd := range []*cobra.Command{recordAddCmd, recordSetCmd, recordDeleteCmd, recordGetCmd} {
+		cmd.Flags().StringVarP(&domain, "domain", "d", "", "Domain of the record (default: domain of --config)")
+		cmd.Flags().StringVarP(&recordType, "type", "t", "", "Record type")
+		cmd.Flags().StringVarP(&configFile, "config", "f", "", "DNS configuration YAML file to keep in sync")
+		if cmd != recordGetCmd {
+			cmd.MarkFlagRequired("type")
+			cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without applying them")
+		}
+	}
+	for _, cmd := range []*cobra.Command{recordAddCmd, recordSetCmd} {
+		cmd.Flags().StringVar(&recordTarget, "target", "", "Record target (required)")
+		cmd.Flags().IntVar(&recordTTL, "ttl", 0, "Record TTL in seconds (default: 3600)")
+		cmd.Flags().IntVar(&recordPriority, "priority", 0, "Record priority (MX and SRV only)")
+		cmd.MarkFlagRequired("target")
+	}
+	recordDeleteCmd.Flags().StringVar(&recordTarget, "target", "", "Only delete records with this target")
+
+	recordCmd.AddCommand(recordAddCmd, recordSetCmd, recordDeleteCmd, recordGetCmd)
+
+	dynhostCmd.PersistentFlags().StringVarP(&domain, "domain", "d", "", "Domain of the DynHost record (required)")
+	dynhostUpdateCmd.Flags().StringVar(&ipURL, "ip-url", "", "URL returning the public IP as plain text (default: ipify)")
+	dynhostUpdateCmd.Flags().StringVar(&ipInterface, "interface", "", "Read the IP from this local interface instead of --ip-url")
+	dynhostUpdateCmd.Flags().BoolVar(&ipv6, "ipv6", false, "Update an AAAA record with the public IPv6 address")
+	dynhostUpdateCmd.Flags().DurationVar(&interval, "interval", 0, "Keep running and check the IP at this interval (e.g. 5m)")
+	dynhostUpdateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without applying them")
+	dynhostCmd.AddCommand(dynhostUpdateCmd, dynhostLoginsCmd)
+
+	dnssecCmd.PersistentFlags().StringVarP(&domain, "domain", "d", "", "Domain of the zone (required)")
+	for _, cmd := range []*cobra.Command{dnssecEnableCmd, dnssecDisableCmd} {
+		cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without applying them")
+	}
+	dnssecCmd.AddCommand(dnssecStatusCmd, dnssecEnableCmd, dnssecDisableCmd)
+
+	acmeCmd.PersistentFlags().StringVarP(&domain, "domain", "d", "", "OVH zone of the challenge (default: detected from the zones of the account)")
+	acmePresentCmd.Flags().IntVar(&challengeTTL, "ttl", acme.DefaultTTL, "Challenge record TTL in seconds")
+	acmePresentCmd.Flags().BoolVar(&wait, "wait", false, "Wait until the authoritative name servers answer the challenge")
+	acmePresentCmd.Flags().DurationVar(&waitTimeout, "wait-timeout", 5*time.Minute, "Maximum time to wait with --wait")
+	acmeCmd.AddCommand(acmePresentCmd, acmeCleanupCmd)
+
+	verifyCmd.Flags().StringVarP(&configFile, "config", "f", "", "DNS configuration YAML file (required)")
+	verifyCmd.Flags().StringSliceVar(&servers, "server", nil, "DNS server to query as host[:port] (default: authoritative name servers from OVH)")
+	verifyCmd.Flags().BoolVar(&authoritative, "authoritative", false, "Query the --server hosts as authoritative servers, without recursion and with exact TTLs")
+
+	if configPath == "" {
+		verifyCmd.MarkFlagRequired("config")
+	}
+
+	cloneCmd.Flags().StringVar(&cloneFrom, "from", "", "Live domain to copy")
+	cloneCmd.Flags().StringVar(&cloneFromFile, "from-file", "", "DNS configuration YAML file to copy")
+	cloneCmd.Flags().StringVar(&cloneTo, "to", "", "Domain to apply the copy to (required)")
+	cloneCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Also write the copied zone to this YAML file")
+	cloneCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without applying them")
+	cloneCmd.MarkFlagsOneRequired("from", "from-file")
+	cloneCmd.MarkFlagsMutuallyExclusive("from", "from-file")
+	cloneCmd.MarkFlagRequired("to")
+
+	loginCmd.Flags().StringSliceVar(&loginZones, "zone", nil, "Only grant access to this DNS zone (repeatable, default: all zones)")
+	loginCmd.Flags().StringVar(&loginEndpoint, "endpoint", "", "OVH API endpoint (default: from the credentials sources, or ovh-eu)")
+	loginCmd.Flags().StringVar(&applicationKey, "application-key", "", "Application key (default: from the credentials sources)")
+	loginCmd.Flags().StringVar(&appSecret, "application-secret", "", "Application secret (default: from the credentials sources)")
+	loginCmd.Flags().DurationVar(&loginTimeout, "timeout", 10*time.Minute, "Maximum time to wait for the validation")
+
+	whoamiCmd.Flags().StringSliceVarP(&configFiles, "config", "f", nil, "DNS configuration file whose zone must be manageable (repeatable)")
+	whoamiCmd.Flags().StringSliceVar(&checkZones, "zone", nil, "DNS zone that must be manageable (repeatable)")
+
+	schemaCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the schema to this file instead of standard output")
+
+	compareCmd.Flags().BoolVar(&rewrite, "rewrite", false, "Move host names of the first zone to the domain of the second before comparing, as clone does")
+
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(pullCmd)
+	rootCmd.AddCommand(recordCmd)
+	rootCmd.AddCommand(dynhostCmd)
+	rootCmd.AddCommand(dnssecCmd)
+	rootCmd.AddCommand(acmeCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(cloneCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(whoamiCmd)
+	rootCmd.AddCommand(schemaCmd)
 }
 
 func runExport(cmd *cobra.Command, args []string) error {
@@ -95,7 +457,7 @@ func runExport(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	client, err := setupOVHClient(credentialsFile)
+	client, err := setupOVHClient(credentialsFile, profile)
 	if err != nil {
 		return err
 	}
@@ -105,16 +467,21 @@ func runExport(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	zone.DNSSEC = syncer.LiveDNSSEC(domain)
+
+	if !noDefaults {
+		config.FactorDefaults(zone)
+	}
 
 	if outputFile == "" {
 		outputFile = domain + ".yaml"
 	}
 
-	if err := config.SaveDNSZone(zone, outputFile); err != nil {
+	if err := config.SaveDNSZone(zone, outputFile, groupComments); err != nil {
 		return err
 	}
 
-	log.Printf("Exported %d DNS records for domain %s to %s", len(zone.Records), domain, outputFile)
+	slog.Info("Exported DNS records", "op", "export", "zone", domain, "records", len(zone.Records), "file", outputFile)
 	return nil
 }
 
@@ -132,7 +499,7 @@ func runApply(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	client, err := setupOVHClient(credentialsFile)
+	client, err := setupOVHClient(credentialsFile, profile)
 	if err != nil {
 		return err
 	}
@@ -150,16 +517,796 @@ func runApply(cmd *cobra.Command, args []string) error {
 	}
 
 	if dryRun && result.HasChanges() {
-		log.Println("Dry run completed. Use --dry-run=false to apply changes.")
+		slog.Info("Dry run completed. Use --dry-run=false to apply changes.", "op", "apply", "zone", zone.Domain)
 	} else if result.HasChanges() {
-		log.Println("DNS zone sync completed successfully")
+		slog.Info("DNS zone sync completed successfully", "op", "apply", "zone", zone.Domain)
+
+		if wait {
+			return waitForPropagation(client, zone.Domain, result.Expectations())
+		}
 	}
 
 	return nil
 }
 
-func main() {
-	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+// waitForPropagation polls the authoritative servers of a zone until the
+// expectations hold or --wait-timeout expires, and reports each record
+func waitForPropagation(client *ovh.Client, zoneName string, expectations []resolve.Expectation) error {
+	resolver, err := resolve.NewAuthoritativeResolver(client, zoneName, resolve.DefaultTimeout)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+
+	slog.Info("Waiting for changes to be live", "op", "wait", "zone", zoneName, "changes", len(expectations), "servers", resolver.Servers())
+	statuses := resolver.WaitForRecords(ctx, zoneName, expectations, 5*time.Second)
+
+	pending := 0
+	for _, status := range statuses {
+		if status.Ready {
+			continue
+		}
+		pending++
+
+		attrs := []any{"op", "wait", "zone", zoneName, "key", status.Record.Name + ":" + status.Record.Type,
+			"fqdn", resolve.RecordFQDN(zoneName, status.Record.Name)}
+		switch {
+		case status.Err != nil:
+			slog.Warn("Record could not be checked", append(attrs, "error", status.Err)...)
+		case status.Absent:
+			slog.Warn("Record is still answered", append(attrs, "target", status.Record.Target)...)
+		default:
+			slog.Warn("Record is not live", append(attrs, "expected", status.Record.Target, "observed", status.Observed)...)
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%d of %d changes not live after %s", pending, len(statuses), waitTimeout)
+	}
+
+	slog.Info("All changes are live", "op", "wait", "zone", zoneName, "changes", len(statuses))
+	return nil
+}
+
+func runPull(cmd *cobra.Command, args []string) error {
+	_, _, envConfigPath := config.LoadAppConfig()
+
+	var err error
+	configFile, err = resolveValueWithEnvFallback(configFile, envConfigPath, "config", "OVH_CONFIG_PATH")
+	if err != nil {
+		return err
+	}
+
+	file, err := config.OpenZoneFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	syncer := sync.NewSyncer(client, dryRun)
+	result, err := syncer.PullZone(file, prune)
+	if err != nil {
+		return err
+	}
+
+	result.PrintSummary()
+
+	if !result.HasChanges() {
+		return nil
+	}
+
+	if dryRun {
+		slog.Info("Dry run completed. Use --dry-run=false to update the file.", "op", "pull", "zone", result.Zone)
+		return nil
+	}
+
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	slog.Info("Updated zone file with live changes", "op", "pull", "zone", result.Zone, "file", configFile)
+	return nil
+}
+
+// recordFromFlags builds the record described by the record command flags
+func recordFromFlags(name string) *config.DNSRecord {
+	if name == "@" {
+		name = ""
+	}
+	return &config.DNSRecord{
+		Name:     name,
+		Type:     strings.ToUpper(recordType),
+		Target:   recordTarget,
+		TTL:      recordTTL,
+		Priority: recordPriority,
+	}
+}
+
+// openRecordZoneFile opens the --config file of a record command, if any, and
+// resolves the domain from the flags, OVH_DOMAIN or the file itself. Records
+// without --ttl get the TTL from the defaults of the file.
+func openRecordZoneFile() (*config.ZoneFile, error) {
+	var file *config.ZoneFile
+	if configFile != "" {
+		var err error
+		file, err = config.OpenZoneFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		zone, err := file.Zone()
+		if err != nil {
+			return nil, err
+		}
+
+		if recordTTL == 0 && recordType != "" {
+			recordTTL = zone.DefaultTTLFor(recordType)
+		}
+
+		if domain == "" {
+			domain = zone.Domain
+		} else if domain != zone.Domain {
+			return nil, fmt.Errorf("domain %s does not match domain %s of %s", domain, zone.Domain, configFile)
+		}
+	}
+
+	_, envDomain, _ := config.LoadAppConfig()
+	var err error
+	domain, err = resolveValueWithEnvFallback(domain, envDomain, "domain", "OVH_DOMAIN")
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// saveRecordZoneFile writes the zone file of a record command unless in dry-run mode
+func saveRecordZoneFile(file *config.ZoneFile) error {
+	if file == nil || dryRun {
+		return nil
+	}
+
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	slog.Info("Updated zone file", "op", "record", "file", file.Path())
+	return nil
+}
+
+func runRecordAdd(cmd *cobra.Command, args []string) error {
+	file, err := openRecordZoneFile()
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	// Edit the file first so that templated records are refused before the
+	// live zone changes
+	record := recordFromFlags(args[0])
+	if file != nil {
+		if _, err := file.SetRecord(record); err != nil {
+			return err
+		}
+	}
+
+	syncer := sync.NewSyncer(client, dryRun)
+	if err := syncer.AddRecord(domain, record); err != nil {
+		return err
+	}
+
+	return saveRecordZoneFile(file)
+}
+
+func runRecordSet(cmd *cobra.Command, args []string) error {
+	file, err := openRecordZoneFile()
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	// Edit the file first so that templated records are refused before the
+	// live zone changes
+	record := recordFromFlags(args[0])
+	if file != nil {
+		if _, err := file.SetRecord(record); err != nil {
+			return err
+		}
+	}
+
+	syncer := sync.NewSyncer(client, dryRun)
+	if _, err := syncer.SetRecord(domain, record); err != nil {
+		return err
+	}
+
+	return saveRecordZoneFile(file)
+}
+
+func runRecordDelete(cmd *cobra.Command, args []string) error {
+	file, err := openRecordZoneFile()
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	// Edit the file first so that templated records are refused before the
+	// live zone changes
+	record := recordFromFlags(args[0])
+	removed := false
+	if file != nil {
+		removed, err = file.RemoveRecord(record.Name, record.Type, recordTarget)
+		if err != nil {
+			return err
+		}
+	}
+
+	syncer := sync.NewSyncer(client, dryRun)
+	deleted, err := syncer.DeleteRecord(domain, record.Name, record.Type, recordTarget)
+	if err != nil {
+		return err
 	}
-}
\ No newline at end of file
+
+	if len(deleted) == 0 {
+		return fmt.Errorf("no record %s found in zone %s", args[0]+" "+record.Type, domain)
+	}
+
+	if file != nil && !removed {
+		slog.Warn("Record was not declared in the zone file", "op", "delete", "zone", domain, "key", args[0]+":"+record.Type,
+			"target", recordTarget, "file", file.Path())
+	}
+
+	return saveRecordZoneFile(file)
+}
+
+func runRecordGet(cmd *cobra.Command, args []string) error {
+	if _, err := openRecordZoneFile(); err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	record := recordFromFlags(args[0])
+	syncer := sync.NewSyncer(client, false)
+	records, err := syncer.GetRecords(domain, record.Name, record.Type)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return fmt.Errorf("no record %s found in zone %s", args[0], domain)
+	}
+
+	data, err := config.MarshalDNSZone(&config.DNSZone{Domain: domain, Records: records}, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func runDynHost(cmd *cobra.Command, args []string) error {
+	_, envDomain, _ := config.LoadAppConfig()
+
+	var err error
+	domain, err = resolveValueWithEnvFallback(domain, envDomain, "domain", "OVH_DOMAIN")
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	detector := dynhost.NewDetector(ipURL, ipInterface, ipv6)
+	updater := dynhost.NewUpdater(client, detector, domain, args[0], ipv6, dryRun)
+
+	if interval <= 0 {
+		_, err := updater.Update()
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Checking public IP periodically", "op", "dynhost", "zone", domain, "interval", interval)
+	return updater.Run(ctx, interval)
+}
+
+func runDynHostLogins(cmd *cobra.Command, args []string) error {
+	_, envDomain, _ := config.LoadAppConfig()
+
+	var err error
+	domain, err = resolveValueWithEnvFallback(domain, envDomain, "domain", "OVH_DOMAIN")
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	logins, err := client.GetDynHostLogins(domain)
+	if err != nil {
+		return err
+	}
+
+	if len(logins) == 0 {
+		slog.Info("No DynHost logins", "op", "dynhost", "zone", domain)
+		return nil
+	}
+
+	for _, login := range logins {
+		fmt.Printf("%s\t%s\n", login.Login, login.SubDomain)
+	}
+	return nil
+}
+
+// setupDNSSEC resolves the domain of the dnssec commands and creates the client
+func setupDNSSEC() (*ovh.Client, error) {
+	_, envDomain, _ := config.LoadAppConfig()
+
+	var err error
+	domain, err = resolveValueWithEnvFallback(domain, envDomain, "domain", "OVH_DOMAIN")
+	if err != nil {
+		return nil, err
+	}
+
+	return setupOVHClient(credentialsFile, profile)
+}
+
+func runDNSSECStatus(cmd *cobra.Command, args []string) error {
+	client, err := setupDNSSEC()
+	if err != nil {
+		return err
+	}
+
+	dnssec, err := client.GetDNSSEC(domain)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(dnssec.Status)
+	return nil
+}
+
+func runDNSSECEnable(cmd *cobra.Command, args []string) error {
+	return setDNSSEC(true)
+}
+
+func runDNSSECDisable(cmd *cobra.Command, args []string) error {
+	return setDNSSEC(false)
+}
+
+// setDNSSEC switches DNSSEC signing the way apply does for zone files
+// declaring a dnssec setting
+func setDNSSEC(enabled bool) error {
+	client, err := setupDNSSEC()
+	if err != nil {
+		return err
+	}
+
+	syncer := sync.NewSyncer(client, dryRun)
+	result, err := syncer.SyncDNSSEC(domain, enabled)
+	if err != nil {
+		return err
+	}
+
+	if result.DNSSEC == nil {
+		slog.Info("DNSSEC is already in the requested state", "op", "dnssec", "zone", domain, "dnssec", enabled)
+	} else if dryRun {
+		slog.Info("Dry run completed. Use --dry-run=false to apply changes.", "op", "dnssec", "zone", domain)
+	}
+	return nil
+}
+
+// acmeChallengeArgs returns the domain and validation value of an acme
+// command, falling back to the variables certbot sets for its hooks
+func acmeChallengeArgs(args []string) (string, string, error) {
+	fqdn, value := os.Getenv("CERTBOT_DOMAIN"), os.Getenv("CERTBOT_VALIDATION")
+	if len(args) > 0 {
+		fqdn = args[0]
+	}
+	if len(args) > 1 {
+		value = args[1]
+	}
+
+	if fqdn == "" || value == "" {
+		return "", "", fmt.Errorf("fqdn and value are required (pass them as arguments or set CERTBOT_DOMAIN and CERTBOT_VALIDATION)")
+	}
+	return fqdn, value, nil
+}
+
+func runACMEPresent(cmd *cobra.Command, args []string) error {
+	fqdn, value, err := acmeChallengeArgs(args)
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	hook := acme.NewHook(client, domain, challengeTTL)
+	if _, err := hook.Present(fqdn, value); err != nil {
+		return err
+	}
+
+	if wait {
+		return hook.Wait(fqdn, value, waitTimeout)
+	}
+	return nil
+}
+
+func runACMECleanup(cmd *cobra.Command, args []string) error {
+	fqdn, value, err := acmeChallengeArgs(args)
+	if err != nil {
+		return err
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	return acme.NewHook(client, domain, 0).Cleanup(fqdn, value)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	_, _, envConfigPath := config.LoadAppConfig()
+
+	var err error
+	configFile, err = resolveValueWithEnvFallback(configFile, envConfigPath, "config", "OVH_CONFIG_PATH")
+	if err != nil {
+		return err
+	}
+
+	zone, err := config.LoadDNSZone(configFile)
+	if err != nil {
+		return err
+	}
+
+	var resolver *resolve.Resolver
+	if len(servers) > 0 && authoritative {
+		resolver = resolve.NewResolver(servers, resolve.DefaultTimeout)
+	} else if len(servers) > 0 {
+		resolver = resolve.NewRecursiveResolver(servers, resolve.DefaultTimeout)
+	} else {
+		client, err := setupOVHClient(credentialsFile, profile)
+		if err != nil {
+			return err
+		}
+
+		resolver, err = resolve.NewAuthoritativeResolver(client, zone.Domain, resolve.DefaultTimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Verifying records", "op", "verify", "zone", zone.Domain, "records", len(zone.Records), "servers", resolver.Servers())
+	mismatches := resolver.VerifyZone(zone)
+
+	for _, mismatch := range mismatches {
+		attrs := []any{"op", "verify", "zone", zone.Domain, "key", mismatch.Record.Name + ":" + mismatch.Record.Type,
+			"fqdn", resolve.RecordFQDN(zone.Domain, mismatch.Record.Name), "problem", mismatch.Problem}
+		if mismatch.Server != "" {
+			attrs = append(attrs, "server", mismatch.Server)
+		}
+		slog.Warn("Record mismatch", attrs...)
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("verification found %d mismatches", len(mismatches))
+	}
+
+	slog.Info("All records match", "op", "verify", "zone", zone.Domain, "records", len(zone.Records))
+	return nil
+}
+
+func runClone(cmd *cobra.Command, args []string) error {
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	syncer := sync.NewSyncer(client, dryRun)
+
+	var source *config.DNSZone
+	if cloneFromFile != "" {
+		source, err = config.LoadDNSZone(cloneFromFile)
+	} else {
+		source, err = syncer.ExportZone(cloneFrom)
+	}
+	if err != nil {
+		return err
+	}
+
+	cloned, result, err := syncer.CloneZone(source, cloneTo)
+	if err != nil {
+		return err
+	}
+
+	result.PrintSummary()
+
+	if outputFile != "" {
+		if err := config.SaveDNSZone(cloned, outputFile, false); err != nil {
+			return err
+		}
+		slog.Info("Wrote copied zone", "op", "clone", "zone", cloneTo, "file", outputFile)
+	}
+
+	if result.HasErrors() {
+		return fmt.Errorf("clone completed with %d errors", len(result.Errors))
+	}
+
+	if dryRun && result.HasChanges() {
+		slog.Info("Dry run completed. Use --dry-run=false to apply changes.", "op", "clone", "zone", cloneTo)
+	} else if result.HasChanges() {
+		slog.Info("Cloned zone", "op", "clone", "zone", cloneTo, "source", source.Domain)
+	}
+
+	return nil
+}
+
+// loadZoneSource loads a zone from a YAML file when source names an existing
+// file, or from the live domain otherwise
+func loadZoneSource(syncer **sync.Syncer, source string) (*config.DNSZone, error) {
+	if info, err := os.Stat(source); err == nil && !info.IsDir() {
+		return config.LoadDNSZone(source)
+	}
+
+	if *syncer == nil {
+		client, err := setupOVHClient(credentialsFile, profile)
+		if err != nil {
+			return nil, err
+		}
+		*syncer = sync.NewSyncer(client, true)
+	}
+
+	zone, err := (*syncer).ExportZone(source)
+	if err != nil {
+		return nil, err
+	}
+	zone.DNSSEC = (*syncer).LiveDNSSEC(source)
+	return zone, nil
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	var syncer *sync.Syncer
+
+	left, err := loadZoneSource(&syncer, args[0])
+	if err != nil {
+		return err
+	}
+
+	right, err := loadZoneSource(&syncer, args[1])
+	if err != nil {
+		return err
+	}
+
+	if rewrite {
+		left = sync.RewriteZone(left, right.Domain)
+	}
+
+	comparison := sync.CompareZones(left, right)
+	comparison.PrintSummary(args[0], args[1])
+
+	if !comparison.Equal() {
+		return fmt.Errorf("zones differ")
+	}
+	return nil
+}
+
+func runLogin(cmd *cobra.Command, args []string) error {
+	if config.IsEncrypted(credentialsFile) {
+		return fmt.Errorf("credentials file %s is encrypted, use a plain file and encrypt it afterwards", credentialsFile)
+	}
+
+	creds, err := config.ResolveOVHApplication(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+	if loginEndpoint != "" {
+		creds.Endpoint = loginEndpoint
+	}
+	if applicationKey != "" {
+		creds.ApplicationKey = applicationKey
+	}
+	if appSecret != "" {
+		creds.ApplicationSecret = appSecret
+	}
+	if creds.ApplicationKey == "" || creds.ApplicationSecret == "" {
+		return fmt.Errorf("an application key and secret are required (create an application on the createApp page of your OVH API endpoint, then use --application-key and --application-secret)")
+	}
+
+	client, err := newOVHClient(creds)
+	if err != nil {
+		return err
+	}
+
+	token, err := client.RequestCredential(ovh.AccessRules(loginZones), "")
+	if err != nil {
+		return fmt.Errorf("failed to request a consumer key: %w", err)
+	}
+
+	fmt.Printf("Open this URL and log in with your OVH account to grant access:\n\n  %s\n\n", token.ValidationURL)
+
+	creds.ConsumerKey = token.ConsumerKey
+	pending, err := newOVHClient(creds)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
+
+	slog.Info("Waiting for the consumer key to be validated", "op", "login")
+	credential, err := pending.WaitForValidation(ctx, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	if err := config.SaveOVHCredentials(credentialsFile, profile, creds); err != nil {
+		return err
+	}
+
+	attrs := []any{"op", "login", "file", credentialsFile}
+	if credential.Expiration != nil {
+		attrs = append(attrs, "expires", credential.Expiration.Format(time.RFC1123))
+	}
+	slog.Info("Consumer key validated and saved", attrs...)
+	return nil
+}
+
+func runWhoami(cmd *cobra.Command, args []string) error {
+	zones := append([]string{}, checkZones...)
+	for _, file := range configFiles {
+		zone, err := config.LoadDNSZone(file)
+		if err != nil {
+			return err
+		}
+		if zone.Domain == "" {
+			return fmt.Errorf("%s does not set a domain", file)
+		}
+		zones = append(zones, zone.Domain)
+	}
+
+	client, err := setupOVHClient(credentialsFile, profile)
+	if err != nil {
+		return err
+	}
+
+	if account, err := client.GetAccount(); err != nil {
+		fmt.Printf("Account:      unknown (%v)\n", err)
+	} else {
+		fmt.Printf("Account:      %s (%s)\n", account.Nichandle, strings.TrimSpace(account.FirstName+" "+account.Name+" <"+account.Email+">"))
+	}
+
+	if client.UsesOAuth2() {
+		return checkServiceAccount(client, zones)
+	}
+
+	credential, err := client.GetCurrentCredential()
+	if err != nil {
+		return fmt.Errorf("failed to check the consumer key: %w", err)
+	}
+
+	fmt.Printf("Application:  %d\n", credential.ApplicationID)
+	fmt.Printf("Consumer key: %d, %s\n", credential.CredentialID, credential.Status)
+	if credential.Creation != nil {
+		fmt.Printf("Created:      %s\n", credential.Creation.Format(time.RFC1123))
+	}
+	if credential.Expiration != nil {
+		remaining := "expired"
+		if left := time.Until(*credential.Expiration); left > 0 {
+			remaining = fmt.Sprintf("in %d days", int(left.Hours()/24))
+		}
+		fmt.Printf("Expires:      %s (%s)\n", credential.Expiration.Format(time.RFC1123), remaining)
+	} else {
+		fmt.Println("Expires:      never")
+	}
+	fmt.Println("Access rules:")
+	for _, rule := range credential.Rules {
+		fmt.Printf("  %-6s %s\n", rule.Method, rule.Path)
+	}
+
+	if credential.Status != ovh.CredentialValidated {
+		return fmt.Errorf("consumer key is %s", credential.Status)
+	}
+
+	denied := 0
+	for _, zone := range zones {
+		missing := ovh.MissingZoneAccess(credential.Rules, zone)
+		if len(missing) == 0 {
+			slog.Info("Access rules allow managing the zone", "op", "whoami", "zone", zone)
+			continue
+		}
+
+		denied++
+		for _, call := range missing {
+			slog.Warn("Access rules do not allow call", "op", "whoami", "zone", zone, "method", call.Method, "path", call.Path)
+		}
+	}
+
+	if denied > 0 {
+		return fmt.Errorf("credentials cannot manage %d of %d zones", denied, len(zones))
+	}
+	return nil
+}
+
+// checkServiceAccount checks that an OAuth2 service account can read the
+// given zones. Its IAM policies cannot be listed, so write access is only
+// known once a change is made.
+func checkServiceAccount(client *ovh.Client, zones []string) error {
+	fmt.Println("Auth:         OAuth2 service account")
+
+	denied := 0
+	for _, zone := range zones {
+		if _, err := client.GetZone(zone); err != nil {
+			denied++
+			slog.Warn("Zone cannot be read", "op", "whoami", "zone", zone, "error", err)
+			continue
+		}
+		slog.Info("Zone is readable, write access depends on IAM policies and is not checked", "op", "whoami", "zone", zone)
+	}
+
+	if denied > 0 {
+		return fmt.Errorf("credentials cannot read %d of %d zones", denied, len(zones))
+	}
+	return nil
+}
+
+func runSchema(cmd *cobra.Command, args []string) error {
+	schema, err := config.GenerateJSONSchema()
+	if err != nil {
+		return err
+	}
+
+	if outputFile == "" {
+		_, err := os.Stdout.Write(schema)
+		return err
+	}
+
+	if err := os.WriteFile(outputFile, schema, 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+	slog.Info("Wrote JSON Schema", "op", "schema", "file", outputFile)
+	return nil
+}
+
+func main() {
+	// Errors are logged once, with any credentials removed
+	rootCmd.SilenceErrors = true
+	err := rootCmd.Execute()
+	closeTraceFile()
+	if err != nil {
+		slog.Error(redact.String(err.Error()))
+		os.Exit(1)
+	}
+}